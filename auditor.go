@@ -0,0 +1,276 @@
+package ktclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// auditorStorageKey is the Storage key under which an Auditor saves its progress.
+const auditorStorageKey = "ktclient/auditor"
+
+// defaultAuditorPollInterval is how often an Auditor checks for new epochs by default.
+const defaultAuditorPollInterval = 10 * time.Minute
+
+// EpochSource fetches the epochs of a key transparency deployment.
+// Applications implement it on top of their API client.
+type EpochSource interface {
+	// LatestEpochID returns the ID of the latest epoch.
+	LatestEpochID() (int, error)
+	// GetEpoch returns the epoch with the given ID.
+	GetEpoch(epochID int) (*Epoch, error)
+}
+
+// AuditorOptions configures an Auditor.
+type AuditorOptions struct {
+	// FirstEpochID is the epoch the audit starts from, when no progress was
+	// saved. Its previous chain hash is trusted.
+	FirstEpochID int
+	// EpochOptions tunes the verification of the epochs. Its Clock is
+	// ignored: epochs are verified as of their certificate time.
+	EpochOptions *EpochOptions
+	// SigningKey, if not nil, verifies the epochs as signed epochs,
+	// see VerifySignedEpoch.
+	SigningKey *EpochSigningKey
+	// Storage, if not nil, persists the progress of the audit.
+	Storage Storage
+	// PollInterval is how often Run checks for new epochs.
+	PollInterval time.Duration
+	// Clock tells the time of the status updates, the system clock if nil.
+	Clock Clock
+}
+
+// AuditorStatus is the state of an Auditor, as served by its HTTP handler.
+type AuditorStatus struct {
+	// Healthy is false once the audit failed, until an audit succeeds.
+	Healthy bool
+	// LastEpochID and LastChainHash identify the last audited epoch.
+	LastEpochID   int
+	LastChainHash string
+	// LatestEpochID is the latest epoch the source announced.
+	LatestEpochID int
+	// LastAudit is the Unix time of the last completed audit.
+	LastAudit int64
+	Error     string `json:",omitempty"`
+}
+
+// auditorProgress is the progress an Auditor saves: the last audited epoch.
+type auditorProgress struct {
+	EpochID   int
+	ChainHash string
+}
+
+// Auditor verifies every epoch of a key transparency deployment, and their
+// chain: each epoch must extend the previous one. It detects gaps in the
+// chain and forks, that is epochs already audited served differently.
+// Epochs are verified as of their certificate time, since the certificates
+// of old epochs expire.
+type Auditor struct {
+	mutex sync.Mutex
+	// auditMutex serializes the audits, so that epochs are audited once.
+	auditMutex sync.Mutex
+	source     EpochSource
+	baseDomain string
+	options    AuditorOptions
+	progress   *auditorProgress
+	status     AuditorStatus
+}
+
+// NewAuditor creates an Auditor of the epochs of the source, resuming
+// from the progress saved to the storage of the options, if any.
+func NewAuditor(source EpochSource, baseDomain string, options *AuditorOptions) (*Auditor, error) {
+	auditor := &Auditor{ //nolint:exhaustruct
+		source:     source,
+		baseDomain: baseDomain,
+	}
+	if options != nil {
+		auditor.options = *options
+	}
+	if auditor.options.PollInterval <= 0 {
+		auditor.options.PollInterval = defaultAuditorPollInterval
+	}
+	if auditor.options.Clock == nil {
+		auditor.options.Clock = SystemClock()
+	}
+	if auditor.options.EpochOptions != nil {
+		epochOptions := *auditor.options.EpochOptions
+		epochOptions.Clock = nil
+		auditor.options.EpochOptions = &epochOptions
+	}
+	if auditor.options.Storage != nil {
+		data, err := auditor.options.Storage.Get(auditorStorageKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "ktclient: load audit progress")
+		}
+		if data != nil {
+			if err := json.Unmarshal(data, &auditor.progress); err != nil {
+				return nil, errors.Wrap(err, "ktclient: invalid audit progress")
+			}
+		}
+	}
+	auditor.status.Healthy = true
+	if auditor.progress != nil {
+		auditor.status.LastEpochID = auditor.progress.EpochID
+		auditor.status.LastChainHash = auditor.progress.ChainHash
+	}
+
+	return auditor, nil
+}
+
+// Audit verifies the epochs published since the last audit. It stops at
+// the first epoch that fails, which is audited again next time.
+// Concurrent calls wait for each other.
+func (a *Auditor) Audit() error {
+	a.auditMutex.Lock()
+	defer a.auditMutex.Unlock()
+	err := a.audit()
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.status.Healthy = err == nil
+	a.status.Error = ""
+	if err != nil {
+		a.status.Error = err.Error()
+	} else {
+		a.status.LastAudit = a.options.Clock.Now().Unix()
+	}
+
+	return err
+}
+
+func (a *Auditor) audit() error {
+	latestEpochID, err := a.source.LatestEpochID()
+	if err != nil {
+		return errors.Wrap(err, "ktclient: get latest epoch ID")
+	}
+	a.mutex.Lock()
+	a.status.LatestEpochID = latestEpochID
+	progress := a.progress
+	a.mutex.Unlock()
+
+	var previous *Epoch
+	nextEpochID := a.options.FirstEpochID
+	if progress != nil {
+		if latestEpochID < progress.EpochID {
+			return fmt.Errorf(
+				"ktclient: %w: latest epoch %d is before audited epoch %d",
+				errEpochFork, latestEpochID, progress.EpochID,
+			)
+		}
+		// Fetch the last audited epoch again, to check that it did not change.
+		previous, err = a.fetch(progress.EpochID)
+		if err != nil {
+			return err
+		}
+		if !equalHashHex(previous.ChainHash, progress.ChainHash) {
+			return fmt.Errorf(
+				"ktclient: %w: epoch %d has chain hash %s, audited with %s",
+				errEpochFork, progress.EpochID, previous.ChainHash, progress.ChainHash,
+			)
+		}
+		nextEpochID = progress.EpochID + 1
+	}
+	for epochID := nextEpochID; epochID <= latestEpochID; epochID++ {
+		epoch, err := a.fetch(epochID)
+		if err != nil {
+			return err
+		}
+		if err := a.verify(epoch); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("ktclient: epoch %d", epochID))
+		}
+		if previous != nil {
+			if err := VerifyEpochLink(previous, epoch); err != nil {
+				return fmt.Errorf("ktclient: %w: %v", errEpochFork, err) //nolint:errorlint
+			}
+		}
+		if err := a.saveProgress(&auditorProgress{EpochID: epoch.EpochID, ChainHash: epoch.ChainHash}); err != nil {
+			return err
+		}
+		previous = epoch
+	}
+
+	return nil
+}
+
+// fetch gets an epoch from the source, reporting a gap if it is missing.
+func (a *Auditor) fetch(epochID int) (*Epoch, error) {
+	epoch, err := a.source.GetEpoch(epochID)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("ktclient: get epoch %d", epochID))
+	}
+	if epoch == nil || epoch.EpochID != epochID {
+		return nil, fmt.Errorf("ktclient: %w: epoch %d is missing", errEpochGap, epochID)
+	}
+
+	return epoch, nil
+}
+
+func (a *Auditor) verify(epoch *Epoch) error {
+	if a.options.SigningKey != nil {
+		_, err := VerifySignedEpoch(
+			epoch, a.baseDomain, epoch.CertificateTime, a.options.SigningKey, a.options.EpochOptions,
+		)
+
+		return err
+	}
+	_, err := VerifyEpochWithOptions(epoch, a.baseDomain, epoch.CertificateTime, a.options.EpochOptions)
+
+	return err
+}
+
+func (a *Auditor) saveProgress(progress *auditorProgress) error {
+	if a.options.Storage != nil {
+		data, err := json.Marshal(progress)
+		if err != nil {
+			return errors.Wrap(err, "ktclient: encode audit progress")
+		}
+		if err := a.options.Storage.Set(auditorStorageKey, data); err != nil {
+			return errors.Wrap(err, "ktclient: save audit progress")
+		}
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.progress = progress
+	a.status.LastEpochID = progress.EpochID
+	a.status.LastChainHash = progress.ChainHash
+
+	return nil
+}
+
+// Run audits the epochs every PollInterval, until the context is done.
+// Failed audits are reported in the status, and retried.
+func (a *Auditor) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.options.PollInterval)
+	defer ticker.Stop()
+	for {
+		_ = a.Audit()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the current state of the audit.
+func (a *Auditor) Status() AuditorStatus {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.status
+}
+
+// ServeHTTP serves the status of the audit in JSON, with the status code
+// 503 Service Unavailable if the audit is failing.
+func (a *Auditor) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	status := a.Status()
+	w.Header().Set("Content-Type", "application/json")
+	if !status.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,334 @@
+package ktclient
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testAuditBaseDomain = "kt.internal"
+
+// testEpochSigner signs chains of epochs, as a private deployment does.
+type testEpochSigner struct {
+	privateKey ed25519.PrivateKey
+	signingKey *EpochSigningKey
+}
+
+func newTestEpochSigner(t *testing.T) *testEpochSigner {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingKey, err := ParseEpochSigningKey(encodeTestPublicKey(t, publicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testEpochSigner{privateKey: privateKey, signingKey: signingKey}
+}
+
+// nextEpoch returns a signed epoch following previous, or the first epoch if nil.
+func (s *testEpochSigner) nextEpoch(previous *Epoch) *Epoch {
+	epoch := &Epoch{ //nolint:exhaustruct
+		EpochID:           1,
+		PreviousChainHash: hex.EncodeToString(make([]byte, sha256.Size)),
+		CertificateTime:   1_689_062_740,
+	}
+	if previous != nil {
+		epoch.EpochID = previous.EpochID + 1
+		epoch.PreviousChainHash = previous.ChainHash
+		epoch.CertificateTime = previous.CertificateTime + 3_600
+	}
+	treeHash := sha256.Sum256([]byte(fmt.Sprintf("tree %d", epoch.EpochID)))
+	epoch.TreeHash = hex.EncodeToString(treeHash[:])
+	s.sign(epoch)
+
+	return epoch
+}
+
+// sign sets the chain hash and signature of the epoch.
+func (s *testEpochSigner) sign(epoch *Epoch) {
+	previousChainHash, _ := hex.DecodeString(epoch.PreviousChainHash)
+	treeHash, _ := hex.DecodeString(epoch.TreeHash)
+	chainHash := sha256.Sum256(append(previousChainHash, treeHash...))
+	epoch.ChainHash = hex.EncodeToString(chainHash[:])
+	name := epochName(chainHash[:], epoch.CertificateTime, epoch.EpochID, 1, testAuditBaseDomain)
+	epoch.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(s.privateKey, []byte(signedEpochContext+name)))
+}
+
+func (s *testEpochSigner) chain(length int) []*Epoch {
+	epochs := make([]*Epoch, 0, length)
+	var previous *Epoch
+	for i := 0; i < length; i++ {
+		previous = s.nextEpoch(previous)
+		epochs = append(epochs, previous)
+	}
+
+	return epochs
+}
+
+// fakeEpochSource serves epochs from memory, and counts the epochs fetched.
+type fakeEpochSource struct {
+	mutex   sync.Mutex
+	epochs  map[int]*Epoch
+	latest  int
+	fetched int
+}
+
+func newFakeEpochSource(epochs []*Epoch) *fakeEpochSource {
+	source := &fakeEpochSource{epochs: make(map[int]*Epoch)} //nolint:exhaustruct
+	source.publish(epochs...)
+
+	return source
+}
+
+func (s *fakeEpochSource) publish(epochs ...*Epoch) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, epoch := range epochs {
+		s.epochs[epoch.EpochID] = epoch
+		if epoch.EpochID > s.latest {
+			s.latest = epoch.EpochID
+		}
+	}
+}
+
+func (s *fakeEpochSource) LatestEpochID() (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.latest, nil
+}
+
+func (s *fakeEpochSource) GetEpoch(epochID int) (*Epoch, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fetched++
+	epoch, ok := s.epochs[epochID]
+	if !ok {
+		return nil, nil
+	}
+	copied := *epoch
+
+	return &copied, nil
+}
+
+func newTestAuditor(t *testing.T, source EpochSource, signer *testEpochSigner, storage Storage) *Auditor {
+	t.Helper()
+	auditor, err := NewAuditor(source, testAuditBaseDomain, &AuditorOptions{ //nolint:exhaustruct
+		FirstEpochID: 1,
+		SigningKey:   signer.signingKey,
+		Storage:      storage,
+		Clock:        NewFakeClock(time.Unix(1_700_000_000, 0)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return auditor
+}
+
+func TestAuditorResumes(t *testing.T) {
+	t.Parallel()
+	signer := newTestEpochSigner(t)
+	epochs := signer.chain(5)
+	source := newFakeEpochSource(epochs[:3])
+	storage := NewMemoryStorage()
+
+	auditor := newTestAuditor(t, source, signer, storage)
+	assert.NoError(t, auditor.Audit())
+	status := auditor.Status()
+	assert.True(t, status.Healthy)
+	assert.Equal(t, 3, status.LastEpochID)
+	assert.Equal(t, epochs[2].ChainHash, status.LastChainHash)
+	assert.Equal(t, int64(1_700_000_000), status.LastAudit)
+
+	// A new auditor resumes from the saved progress, fetching the last
+	// audited epoch again and the new ones only.
+	source.publish(epochs[3:]...)
+	source.fetched = 0
+	auditor = newTestAuditor(t, source, signer, storage)
+	assert.Equal(t, 3, auditor.Status().LastEpochID)
+	assert.NoError(t, auditor.Audit())
+	assert.Equal(t, 5, auditor.Status().LastEpochID)
+	assert.Equal(t, 3, source.fetched)
+}
+
+// blockingEpochSource blocks the requests for the latest epoch ID until
+// release is closed, signaling on started when one is made.
+type blockingEpochSource struct {
+	*fakeEpochSource
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingEpochSource) LatestEpochID() (int, error) {
+	s.started <- struct{}{}
+	<-s.release
+
+	return s.fakeEpochSource.LatestEpochID()
+}
+
+func TestAuditorConcurrentAudits(t *testing.T) {
+	t.Parallel()
+	signer := newTestEpochSigner(t)
+	source := &blockingEpochSource{
+		fakeEpochSource: newFakeEpochSource(signer.chain(20)),
+		started:         make(chan struct{}, 2),
+		release:         make(chan struct{}),
+	}
+	auditor := newTestAuditor(t, source, signer, NewMemoryStorage())
+
+	var wg sync.WaitGroup
+	audit := func() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, auditor.Audit())
+		}()
+	}
+	audit()
+	<-source.started
+	audit()
+	select {
+	case <-source.started:
+		t.Error("the audits run concurrently")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(source.release)
+	wg.Wait()
+	assert.Equal(t, 20, auditor.Status().LastEpochID)
+	// The second audit only fetches the last audited epoch again.
+	assert.Equal(t, 21, source.fetched)
+}
+
+func TestAuditorIgnoresEpochClock(t *testing.T) {
+	t.Parallel()
+	signer := newTestEpochSigner(t)
+	source := newFakeEpochSource(signer.chain(3))
+	epochOptions := &EpochOptions{ //nolint:exhaustruct
+		Freshness: &FreshnessPolicy{MaxEpochAge: time.Hour}, //nolint:exhaustruct
+		Clock:     NewFakeClock(time.Unix(1_800_000_000, 0)),
+	}
+	auditor, err := NewAuditor(source, testAuditBaseDomain, &AuditorOptions{ //nolint:exhaustruct
+		FirstEpochID: 1,
+		SigningKey:   signer.signingKey,
+		EpochOptions: epochOptions,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, auditor.Audit(), "old epochs are verified as of their certificate time")
+	assert.NotNil(t, epochOptions.Clock, "the options of the caller are not modified")
+}
+
+func TestAuditorDetectsForks(t *testing.T) {
+	t.Parallel()
+	signer := newTestEpochSigner(t)
+	epochs := signer.chain(4)
+
+	// An audited epoch served with other contents.
+	source := newFakeEpochSource(epochs[:3])
+	auditor := newTestAuditor(t, source, signer, nil)
+	assert.NoError(t, auditor.Audit())
+	forked := *epochs[2]
+	forked.TreeHash = forked.PreviousChainHash
+	signer.sign(&forked)
+	source.publish(&forked)
+	assert.True(t, errors.Is(auditor.Audit(), errEpochFork))
+	status := auditor.Status()
+	assert.False(t, status.Healthy)
+	assert.Equal(t, 3, status.LastEpochID)
+	assert.NotEmpty(t, status.Error)
+
+	// A new epoch which does not extend the audited one.
+	source = newFakeEpochSource(epochs[:3])
+	auditor = newTestAuditor(t, source, signer, nil)
+	assert.NoError(t, auditor.Audit())
+	source.publish(signer.nextEpoch(&forked))
+	assert.True(t, errors.Is(auditor.Audit(), errEpochFork))
+
+	// The latest epoch going back in time.
+	source = newFakeEpochSource(epochs[:3])
+	auditor = newTestAuditor(t, source, signer, nil)
+	assert.NoError(t, auditor.Audit())
+	source.latest = 2
+	assert.True(t, errors.Is(auditor.Audit(), errEpochFork))
+
+	// The audited epoch served again with uppercase hex is not a fork.
+	source = newFakeEpochSource(epochs[:3])
+	auditor = newTestAuditor(t, source, signer, nil)
+	assert.NoError(t, auditor.Audit())
+	upper := *epochs[2]
+	upper.ChainHash = strings.ToUpper(upper.ChainHash)
+	source.publish(&upper)
+	assert.NoError(t, auditor.Audit())
+}
+
+func TestAuditorDetectsGaps(t *testing.T) {
+	t.Parallel()
+	signer := newTestEpochSigner(t)
+	epochs := signer.chain(4)
+	source := newFakeEpochSource(append(epochs[:2:2], epochs[3]))
+	auditor := newTestAuditor(t, source, signer, nil)
+	assert.True(t, errors.Is(auditor.Audit(), errEpochGap))
+	assert.Equal(t, 2, auditor.Status().LastEpochID)
+
+	// The audit recovers once the epoch is published.
+	source.publish(epochs[2])
+	assert.NoError(t, auditor.Audit())
+	assert.Equal(t, 4, auditor.Status().LastEpochID)
+	assert.True(t, auditor.Status().Healthy)
+}
+
+func TestAuditorRejectsInvalidEpochs(t *testing.T) {
+	t.Parallel()
+	signer, other := newTestEpochSigner(t), newTestEpochSigner(t)
+	epochs := signer.chain(2)
+	source := newFakeEpochSource(append(epochs, other.nextEpoch(epochs[1])))
+	auditor := newTestAuditor(t, source, signer, nil)
+	assert.True(t, errors.Is(auditor.Audit(), errSigningKey))
+	assert.Equal(t, 2, auditor.Status().LastEpochID)
+}
+
+func TestAuditorStatusEndpoint(t *testing.T) {
+	t.Parallel()
+	signer := newTestEpochSigner(t)
+	source := newFakeEpochSource(signer.chain(2))
+	auditor := newTestAuditor(t, source, signer, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		auditor.Run(ctx)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return auditor.Status().LastEpochID == 2 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	recorder := httptest.NewRecorder()
+	auditor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var status AuditorStatus
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+	assert.Equal(t, auditor.Status(), status)
+
+	source.latest = 1
+	assert.Error(t, auditor.Audit())
+	recorder = httptest.NewRecorder()
+	auditor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+}
@@ -0,0 +1,151 @@
+package ktclient
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+// epochCheckpointOriginSuffix ends the origin of the epoch checkpoints.
+const epochCheckpointOriginSuffix = "/epochs"
+
+// EpochCheckpoint is an epoch in the transparency-dev checkpoint format,
+// see https://github.com/transparency-dev/formats: the origin is derived from
+// the base domain, the epoch ID is the tree size, the chain hash is the root
+// hash, and the certificate time is an extension line.
+// Checkpoints let epochs flow into existing witness and monitor tools.
+type EpochCheckpoint struct {
+	BaseDomain      string
+	EpochID         int
+	ChainHash       string
+	CertificateTime int64
+}
+
+// NewEpochCheckpoint returns the checkpoint of an epoch. The epoch should have
+// been verified with VerifyEpoch, only its chain hash is checked again.
+func NewEpochCheckpoint(epoch *Epoch, baseDomain string) (*EpochCheckpoint, error) {
+	return NewEpochCheckpointWithOptions(epoch, baseDomain, nil)
+}
+
+// NewEpochCheckpointWithOptions is NewEpochCheckpoint for an epoch verified
+// with VerifyEpochWithOptions: the chain hash is checked with the tree
+// version and hash suite of the options.
+func NewEpochCheckpointWithOptions(epoch *Epoch, baseDomain string, options *EpochOptions) (*EpochCheckpoint, error) {
+	if options == nil {
+		options = &EpochOptions{} //nolint:exhaustruct
+	}
+	treeVersion, err := resolveTreeVersion(options.TreeVersion)
+	if err != nil {
+		return nil, err
+	}
+	suite, err := resolveHashSuite(options.HashSuite, treeVersion)
+	if err != nil {
+		return nil, err
+	}
+	chainHash, err := verifyChainHash(epoch, suite)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EpochCheckpoint{
+		BaseDomain:      baseDomain,
+		EpochID:         epoch.EpochID,
+		ChainHash:       hex.EncodeToString(chainHash),
+		CertificateTime: epoch.CertificateTime,
+	}, nil
+}
+
+// Origin returns the origin line of the checkpoint.
+func (c *EpochCheckpoint) Origin() string {
+	return epochCheckpointOrigin(c.BaseDomain)
+}
+
+// Text returns the unsigned checkpoint.
+func (c *EpochCheckpoint) Text() (string, error) {
+	chainHash, err := decodeHex(c.ChainHash)
+	if err != nil {
+		return "", fmt.Errorf("ktclient: %w: invalid chain hash: %v", errCheckpoint, err) //nolint:errorlint
+	}
+
+	return epochCheckpointBody(c.EpochID, chainHash, c.CertificateTime, c.BaseDomain), nil
+}
+
+// Sign returns the checkpoint as a signed note, signed with the signed
+// note signer key, in the PRIVATE+KEY+<name>+<hash>+<base64 key> format.
+func (c *EpochCheckpoint) Sign(signerKey string) (string, error) {
+	text, err := c.Text()
+	if err != nil {
+		return "", err
+	}
+	signer, err := note.NewSigner(signerKey)
+	if err != nil {
+		return "", fmt.Errorf("ktclient: %w: invalid signer key: %v", errCheckpoint, err) //nolint:errorlint
+	}
+	signed, err := note.Sign(&note.Note{Text: text}, signer) //nolint:exhaustruct
+	if err != nil {
+		return "", fmt.Errorf("ktclient: %w: %v", errCheckpoint, err) //nolint:errorlint
+	}
+
+	return string(signed), nil
+}
+
+// ParseEpochCheckpoint parses an epoch checkpoint, signed or not.
+// Signatures are not verified: use the note's signers, or a WitnessPolicy.
+func ParseEpochCheckpoint(checkpoint string) (*EpochCheckpoint, error) {
+	text := checkpoint
+	if split := strings.Index(checkpoint, "\n\n"); split >= 0 {
+		text = checkpoint[:split+1]
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) != 5 || lines[4] != "" {
+		return nil, fmt.Errorf("ktclient: %w: expected 4 lines", errCheckpoint)
+	}
+	baseDomain := strings.TrimSuffix(lines[0], epochCheckpointOriginSuffix)
+	if baseDomain == "" || baseDomain == lines[0] {
+		return nil, fmt.Errorf("ktclient: %w: invalid origin %q", errCheckpoint, lines[0])
+	}
+	epochID, err := strconv.Atoi(lines[1])
+	if err != nil || epochID < 0 {
+		return nil, fmt.Errorf("ktclient: %w: invalid tree size %q", errCheckpoint, lines[1])
+	}
+	chainHash, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil || len(chainHash) == 0 {
+		return nil, fmt.Errorf("ktclient: %w: invalid root hash %q", errCheckpoint, lines[2])
+	}
+	certificateTime, err := strconv.ParseInt(lines[3], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("ktclient: %w: invalid certificate time %q", errCheckpoint, lines[3])
+	}
+	parsed := &EpochCheckpoint{
+		BaseDomain:      baseDomain,
+		EpochID:         epochID,
+		ChainHash:       hex.EncodeToString(chainHash),
+		CertificateTime: certificateTime,
+	}
+	if canonical, _ := parsed.Text(); canonical != text {
+		return nil, fmt.Errorf("ktclient: %w: non canonical encoding", errCheckpoint)
+	}
+
+	return parsed, nil
+}
+
+// epochCheckpointOrigin returns the checkpoint origin, which identifies
+// the chain of epochs of a key transparency deployment.
+func epochCheckpointOrigin(baseDomain string) string {
+	return baseDomain + epochCheckpointOriginSuffix
+}
+
+// epochCheckpointBody returns the body of the checkpoint of an epoch.
+func epochCheckpointBody(epochID int, chainHash []byte, certificateTime int64, baseDomain string) string {
+	return fmt.Sprintf(
+		"%s\n%d\n%s\n%d\n",
+		epochCheckpointOrigin(baseDomain),
+		epochID,
+		base64.StdEncoding.EncodeToString(chainHash),
+		certificateTime,
+	)
+}
@@ -0,0 +1,88 @@
+package ktclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mod/sumdb/note"
+)
+
+const (
+	testCheckpointSignerKey   = "PRIVATE+KEY+monitor.example+4e18961e+AYQvkvEiMke6OkB1HmK+m+1I32EJQPNONnz5Rc/QULaV"
+	testCheckpointVerifierKey = "monitor.example+4e18961e+AVMuvByPOKzgieroiNzke9HsTLTlFIs69EGbLOTfXix9"
+	testCheckpointBody        = "dev.proton.wtf/epochs\n" +
+		"46\n" +
+		"UGBiqBtPKuiusvbdLQA62sPNHoSjnRkiWoZT0QEs8NI=\n" +
+		"1689062740\n"
+	testCheckpointNote = testCheckpointBody +
+		"\n" +
+		"— monitor.example ThiWHlB00c2cM5fbpBkm8jjiaL1yWtpuYRtziTbyLQKSDcvbOYNm2Wlg/8vu+2PEjhU+Aen//4rInV2s/Z1eAoEp7g0=\n"
+)
+
+func TestEpochCheckpointExport(t *testing.T) {
+	t.Parallel()
+	epoch := newTestEpoch()
+	checkpoint, err := NewEpochCheckpoint(epoch, "dev.proton.wtf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "dev.proton.wtf/epochs", checkpoint.Origin())
+	text, err := checkpoint.Text()
+	assert.NoError(t, err)
+	assert.Equal(t, testCheckpointBody, text)
+	signed, err := checkpoint.Sign(testCheckpointSignerKey)
+	assert.NoError(t, err)
+	assert.Equal(t, testCheckpointNote, signed)
+
+	verifier, err := note.NewVerifier(testCheckpointVerifierKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := note.Open([]byte(signed), note.VerifierList(verifier))
+	assert.NoError(t, err)
+	assert.Equal(t, testCheckpointBody, opened.Text)
+
+	epoch.ChainHash = "00" + epoch.ChainHash[2:]
+	_, err = NewEpochCheckpoint(epoch, "dev.proton.wtf")
+	assert.Error(t, err)
+}
+
+func TestEpochCheckpointRoundTrip(t *testing.T) {
+	t.Parallel()
+	epoch := newTestEpoch()
+	for _, checkpoint := range []string{testCheckpointBody, testCheckpointNote} {
+		parsed, err := ParseEpochCheckpoint(checkpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, &EpochCheckpoint{
+			BaseDomain:      "dev.proton.wtf",
+			EpochID:         epoch.EpochID,
+			ChainHash:       epoch.ChainHash,
+			CertificateTime: epoch.CertificateTime,
+		}, parsed)
+		text, err := parsed.Text()
+		assert.NoError(t, err)
+		assert.Equal(t, testCheckpointBody, text)
+	}
+}
+
+func TestEpochCheckpointParseErrors(t *testing.T) {
+	t.Parallel()
+	for name, checkpoint := range map[string]string{
+		"empty":             "",
+		"missing line":      "dev.proton.wtf/epochs\n46\nUGBiqBtPKuiusvbdLQA62sPNHoSjnRkiWoZT0QEs8NI=\n",
+		"extra line":        testCheckpointBody + "extension\n",
+		"missing newline":   testCheckpointBody[:len(testCheckpointBody)-1],
+		"other origin":      "dev.proton.wtf\n46\nUGBiqBtPKuiusvbdLQA62sPNHoSjnRkiWoZT0QEs8NI=\n1689062740\n",
+		"empty base domain": "/epochs\n46\nUGBiqBtPKuiusvbdLQA62sPNHoSjnRkiWoZT0QEs8NI=\n1689062740\n",
+		"negative size":     "dev.proton.wtf/epochs\n-46\nUGBiqBtPKuiusvbdLQA62sPNHoSjnRkiWoZT0QEs8NI=\n1689062740\n",
+		"leading zero":      "dev.proton.wtf/epochs\n046\nUGBiqBtPKuiusvbdLQA62sPNHoSjnRkiWoZT0QEs8NI=\n1689062740\n",
+		"invalid root":      "dev.proton.wtf/epochs\n46\nUGBiqBtPKuiusvbdLQA62sPNHoSjnRkiWoZT0QEs8NI\n1689062740\n",
+		"invalid time":      "dev.proton.wtf/epochs\n46\nUGBiqBtPKuiusvbdLQA62sPNHoSjnRkiWoZT0QEs8NI=\nnow\n",
+	} {
+		_, err := ParseEpochCheckpoint(checkpoint)
+		assert.True(t, errors.Is(err, errCheckpoint), name)
+	}
+}
@@ -0,0 +1,67 @@
+package ktclient
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time against which certificates, freshness and
+// timestamps are verified.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock returns the clock of the system.
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+// FakeClock is a Clock that only moves when told to. It replays past
+// verifications deterministically, and tests time-dependent failures.
+type FakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewFakeClock creates a FakeClock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now} //nolint:exhaustruct
+}
+
+// Now implements Clock.
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// Set sets the time of the clock.
+func (c *FakeClock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward by the duration.
+func (c *FakeClock) Advance(duration time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(duration)
+}
+
+// unixTimeClock returns the clock the currentUnixTime parameters stand for:
+// the system clock if not positive, a clock stopped at that time otherwise.
+func unixTimeClock(currentUnixTime int64) Clock {
+	if currentUnixTime <= 0 {
+		return SystemClock()
+	}
+
+	return NewFakeClock(time.Unix(currentUnixTime, 0))
+}
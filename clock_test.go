@@ -0,0 +1,50 @@
+package ktclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	t.Parallel()
+	start := time.Unix(1_689_062_740, 0)
+	clock := NewFakeClock(start)
+	assert.Equal(t, start, clock.Now())
+	clock.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), clock.Now())
+	clock.Set(start)
+	assert.Equal(t, start, clock.Now())
+
+	assert.Equal(t, SystemClock(), unixTimeClock(0))
+	assert.Equal(t, start, unixTimeClock(1_689_062_740).Now())
+}
+
+func TestVerifyEpochWithClock(t *testing.T) {
+	t.Parallel()
+	clock := NewFakeClock(time.Unix(1_689_062_800, 0))
+	options := &EpochOptions{ //nolint:exhaustruct
+		Clock:     clock,
+		Freshness: &FreshnessPolicy{MaxEpochAge: time.Hour}, //nolint:exhaustruct
+	}
+	// The clock takes precedence over the currentUnixTime parameter.
+	_, err := VerifyEpochWithOptions(newTestEpoch(), "dev.proton.wtf", 1, options)
+	assert.NoError(t, err)
+
+	clock.Advance(2 * time.Hour)
+	_, err = VerifyEpochWithOptions(newTestEpoch(), "dev.proton.wtf", 0, options)
+	assert.True(t, errors.Is(err, ErrStaleEpoch))
+
+	// After the certificate expiry.
+	options.Freshness = nil
+	clock.Set(time.Unix(1_696_896_000, 0))
+	_, err = VerifyEpochWithOptions(newTestEpoch(), "dev.proton.wtf", 0, options)
+	assert.Error(t, err)
+
+	// Before the certificate issuance.
+	clock.Set(time.Unix(1_689_033_599, 0))
+	_, err = VerifyEpochWithOptions(newTestEpoch(), "dev.proton.wtf", 0, options)
+	assert.Error(t, err)
+}
@@ -0,0 +1,201 @@
+// Command ktauditor continuously audits the epochs of a key transparency
+// deployment, and serves the status of the audit on a local HTTP endpoint.
+//
+// Usage:
+//
+//	ktauditor -api <url> -base-domain <domain> -state <file> [-listen <address>]
+//	          [-first-epoch <id>] [-interval <duration>] [-signing-key <pem file>]
+//
+// The epochs are read from <url>/epochs/<id> and <url>/epochs/latest, which
+// serve epochs in JSON. The status is served in JSON on /status.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	ktclient "github.com/ProtonMail/pm-key-transparency-go-client"
+)
+
+// httpEpochSource reads epochs from the HTTP API of a deployment.
+type httpEpochSource struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (s *httpEpochSource) getEpoch(path string) (*ktclient.Epoch, error) {
+	response, err := s.client.Get(s.baseURL + "/epochs/" + path)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get epoch %s: %s", path, response.Status) //nolint:goerr113
+	}
+	var epoch ktclient.Epoch
+	if err := json.NewDecoder(response.Body).Decode(&epoch); err != nil {
+		return nil, fmt.Errorf("decode epoch %s: %w", path, err)
+	}
+
+	return &epoch, nil
+}
+
+// LatestEpochID implements ktclient.EpochSource.
+func (s *httpEpochSource) LatestEpochID() (int, error) {
+	epoch, err := s.getEpoch("latest")
+	if err != nil {
+		return 0, err
+	}
+	if epoch == nil {
+		return 0, errors.New("no epoch published") //nolint:goerr113
+	}
+
+	return epoch.EpochID, nil
+}
+
+// GetEpoch implements ktclient.EpochSource.
+func (s *httpEpochSource) GetEpoch(epochID int) (*ktclient.Epoch, error) {
+	return s.getEpoch(fmt.Sprint(epochID))
+}
+
+// fileStorage is a ktclient.Storage saved to a JSON file.
+type fileStorage struct {
+	mutex sync.Mutex
+	path  string
+}
+
+func (s *fileStorage) load() (map[string][]byte, error) {
+	values := make(map[string][]byte)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return values, json.Unmarshal(data, &values) //nolint:wrapcheck
+}
+
+// Get implements ktclient.Storage.
+func (s *fileStorage) Get(key string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	values, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return values[key], nil
+}
+
+// Set implements ktclient.Storage. The file is replaced atomically.
+func (s *fileStorage) Set(key string, value []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	values, err := s.load()
+	if err != nil {
+		return err
+	}
+	values[key] = value
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	if err := os.WriteFile(s.path+".tmp", data, 0o600); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return os.Rename(s.path+".tmp", s.path) //nolint:wrapcheck
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stderr, nil); err != nil {
+		fmt.Fprintln(os.Stderr, "ktauditor:", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the auditor until the context is done. ready, if not nil,
+// is called with the address of the status endpoint once it listens.
+func run(ctx context.Context, args []string, stderr io.Writer, ready func(address string)) error {
+	flags := flag.NewFlagSet("ktauditor", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	api := flags.String("api", "", "base URL of the epoch API")
+	baseDomain := flags.String("base-domain", "", "base domain of the epoch certificates")
+	statePath := flags.String("state", "ktauditor.json", "file saving the progress of the audit")
+	listen := flags.String("listen", "127.0.0.1:8080", "address of the status endpoint")
+	firstEpochID := flags.Int("first-epoch", 1, "epoch to start the audit from")
+	interval := flags.Duration("interval", 10*time.Minute, "interval between audits")
+	signingKeyPath := flags.String("signing-key", "", "PEM public key verifying signed epochs")
+	if err := flags.Parse(args); err != nil {
+		return err //nolint:wrapcheck
+	}
+	if *api == "" || *baseDomain == "" {
+		return errors.New("-api and -base-domain are required") //nolint:goerr113
+	}
+	options := &ktclient.AuditorOptions{ //nolint:exhaustruct
+		FirstEpochID: *firstEpochID,
+		Storage:      &fileStorage{path: *statePath}, //nolint:exhaustruct
+		PollInterval: *interval,
+	}
+	if *signingKeyPath != "" {
+		pemKey, err := os.ReadFile(*signingKeyPath)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+		if options.SigningKey, err = ktclient.ParseEpochSigningKey(string(pemKey)); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+	source := &httpEpochSource{
+		baseURL: strings.TrimSuffix(*api, "/"),
+		client:  &http.Client{Timeout: time.Minute}, //nolint:exhaustruct
+	}
+	auditor, err := ktclient.NewAuditor(source, *baseDomain, options)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/status", auditor)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second} //nolint:exhaustruct
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- server.Serve(listener) }()
+	if ready != nil {
+		ready(listener.Addr().String())
+	}
+
+	auditor.Run(ctx)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err //nolint:wrapcheck
+	}
+	if err := <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	ktclient "github.com/ProtonMail/pm-key-transparency-go-client"
+	"github.com/stretchr/testify/assert"
+)
+
+const testBaseDomain = "kt.internal"
+
+// fakeAPI serves a chain of signed epochs over HTTP.
+type fakeAPI struct {
+	mutex      sync.Mutex
+	privateKey ed25519.PrivateKey
+	epochs     []*ktclient.Epoch
+	// fork changes the tree hashes of the epochs published.
+	fork string
+}
+
+func newFakeAPI(t *testing.T) (*fakeAPI, string) {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "signing-key.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return &fakeAPI{privateKey: privateKey}, keyPath //nolint:exhaustruct
+}
+
+// publish signs and publishes the next epoch.
+func (a *fakeAPI) publish() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	epoch := &ktclient.Epoch{ //nolint:exhaustruct
+		EpochID:           len(a.epochs) + 1,
+		PreviousChainHash: hex.EncodeToString(make([]byte, sha256.Size)),
+		CertificateTime:   1_689_062_740 + int64(len(a.epochs)),
+	}
+	if len(a.epochs) > 0 {
+		epoch.PreviousChainHash = a.epochs[len(a.epochs)-1].ChainHash
+	}
+	treeHash := sha256.Sum256([]byte(a.fork + strconv.Itoa(epoch.EpochID)))
+	epoch.TreeHash = hex.EncodeToString(treeHash[:])
+	previousChainHash, _ := hex.DecodeString(epoch.PreviousChainHash)
+	chainHash := sha256.Sum256(append(previousChainHash, treeHash[:]...))
+	epoch.ChainHash = hex.EncodeToString(chainHash[:])
+	name := fmt.Sprintf(
+		"%s.%s.%d.%d.1.%s",
+		epoch.ChainHash[:32], epoch.ChainHash[32:], epoch.CertificateTime, epoch.EpochID, testBaseDomain,
+	)
+	signature := ed25519.Sign(a.privateKey, []byte("pm-key-transparency signed epoch\n"+name))
+	epoch.Signature = base64.StdEncoding.EncodeToString(signature)
+	a.epochs = append(a.epochs, epoch)
+}
+
+func (a *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	path := r.URL.Path[len("/epochs/"):]
+	index := len(a.epochs) - 1
+	if path != "latest" {
+		epochID, err := strconv.Atoi(path)
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+
+			return
+		}
+		index = epochID - 1
+	}
+	if index < 0 || index >= len(a.epochs) {
+		http.NotFound(w, r)
+
+		return
+	}
+	_ = json.NewEncoder(w).Encode(a.epochs[index])
+}
+
+func getStatus(t *testing.T, address string) (int, *ktclient.AuditorStatus) {
+	t.Helper()
+	response, err := http.Get("http://" + address + "/status") //nolint:noctx
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var status ktclient.AuditorStatus
+	if err := json.NewDecoder(response.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+
+	return response.StatusCode, &status
+}
+
+func TestAuditorAgainstFakeServer(t *testing.T) {
+	t.Parallel()
+	api, keyPath := newFakeAPI(t)
+	for i := 0; i < 3; i++ {
+		api.publish()
+	}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	args := []string{
+		"-api", server.URL, "-base-domain", testBaseDomain, "-state", statePath,
+		"-listen", "127.0.0.1:0", "-interval", "10ms", "-signing-key", keyPath,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	addresses := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, args, &bytes.Buffer{}, func(address string) { addresses <- address }) }()
+	address := <-addresses
+	assert.Eventually(t, func() bool {
+		_, status := getStatus(t, address)
+
+		return status.LastEpochID == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	api.publish()
+	assert.Eventually(t, func() bool {
+		code, status := getStatus(t, address)
+
+		return code == http.StatusOK && status.LastEpochID == 4 && status.Healthy
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+
+	// A restarted auditor resumes, and detects the epochs rewritten since.
+	api.mutex.Lock()
+	api.epochs = api.epochs[:3]
+	api.fork = "fork "
+	api.mutex.Unlock()
+	api.publish()
+	api.publish()
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go func() { done <- run(ctx, args, &bytes.Buffer{}, func(address string) { addresses <- address }) }()
+	address = <-addresses
+	assert.Eventually(t, func() bool {
+		code, status := getStatus(t, address)
+
+		return code == http.StatusServiceUnavailable && status.LastEpochID == 4 && status.Error != ""
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestRunRequiresFlags(t *testing.T) {
+	t.Parallel()
+	assert.Error(t, run(context.Background(), []string{"-api", "http://localhost"}, &bytes.Buffer{}, nil))
+	assert.Error(t, run(context.Background(), []string{"-unknown"}, &bytes.Buffer{}, nil))
+}
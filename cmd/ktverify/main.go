@@ -0,0 +1,375 @@
+// Command ktverify verifies key transparency epochs, insertion proofs and
+// proof bundles offline, and inspects epoch certificates.
+//
+// Usage:
+//
+//	ktverify epoch -base-domain <domain> [-time <unix>] [-json] <epoch.json>
+//	ktverify proof [-json] <proof.json>
+//	ktverify chain -base-domain <domain> [-time <unix>] [-json] <epochs.json>
+//	ktverify bundle [-time <unix>] [-json] <bundle>
+//	ktverify inspect-cert [-json] <chain.pem>
+//
+// The exit code tells the stage at which the verification failed.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	ktclient "github.com/ProtonMail/pm-key-transparency-go-client"
+)
+
+// Exit codes, one per failure stage.
+const (
+	exitOK          = 0
+	exitFailure     = 1
+	exitUsage       = 2
+	exitInput       = 3
+	exitChainHash   = 4
+	exitCertificate = 5
+	exitSCT         = 6
+	exitVRF         = 7
+	exitMerkleProof = 8
+	exitEpochLink   = 9
+)
+
+// Failure stages, reported in the output.
+const (
+	stageInput       = "input"
+	stageChainHash   = "chain hash"
+	stageCertificate = "certificate"
+	stageSCT         = "SCT"
+	stageVRF         = "VRF"
+	stageMerkleProof = "Merkle proof"
+	stageEpochLink   = "epoch link"
+	stageOther       = "other"
+)
+
+var stageExitCodes = map[string]int{
+	stageInput:       exitInput,
+	stageChainHash:   exitChainHash,
+	stageCertificate: exitCertificate,
+	stageSCT:         exitSCT,
+	stageVRF:         exitVRF,
+	stageMerkleProof: exitMerkleProof,
+	stageEpochLink:   exitEpochLink,
+	stageOther:       exitFailure,
+}
+
+// transcriptStages maps the transcript steps to their failure stage.
+var transcriptStages = map[string]string{
+	"chain hash":             stageChainHash,
+	"epoch age":              stageCertificate,
+	"witness cosignatures":   stageOther,
+	"parse certificates":     stageCertificate,
+	"SCT":                    stageSCT,
+	"certificate chain":      stageCertificate,
+	"CT log inclusion":       stageSCT,
+	"revocation":             stageCertificate,
+	"certificate times":      stageCertificate,
+	"alternate names":        stageCertificate,
+	"epoch signature":        stageCertificate,
+	"VRF public key":         stageVRF,
+	"email canonicalization": stageVRF,
+	"VRF proof":              stageVRF,
+	"leaf":                   stageMerkleProof,
+	"root hash":              stageMerkleProof,
+}
+
+// result is the outcome of a command, printed as JSON with -json.
+type result struct {
+	Command     string
+	OK          bool
+	Stage       string                    `json:",omitempty"`
+	Error       string                    `json:",omitempty"`
+	Transcripts []*ktclient.Transcript    `json:",omitempty"`
+	Certificate *ktclient.CertificateInfo `json:",omitempty"`
+}
+
+func (r *result) fail(stage string, err error) *result {
+	r.OK, r.Stage, r.Error = false, stage, err.Error()
+
+	return r
+}
+
+// failTranscript fails the result at the stage of the transcript step which
+// failed with err, or else of the first failed step: the steps failing after
+// it, such as SCT checks, did not stop the verification.
+func (r *result) failTranscript(transcript *ktclient.Transcript, err error) *result {
+	var failed *ktclient.TranscriptStep
+	for _, step := range transcript.Steps {
+		if step.Outcome != ktclient.TranscriptStepFailed {
+			continue
+		}
+		if strings.Contains(err.Error(), step.Error) {
+			failed = step
+
+			break
+		}
+		if failed == nil {
+			failed = step
+		}
+	}
+	stage := stageOther
+	if failed != nil {
+		if transcriptStage, ok := transcriptStages[failed.Name]; ok {
+			stage = transcriptStage
+		}
+	}
+
+	return r.fail(stage, err)
+}
+
+type command func(flags *flag.FlagSet, args []string) *result
+
+var commands = map[string]command{
+	"epoch":        verifyEpochCommand,
+	"proof":        verifyProofCommand,
+	"chain":        verifyChainCommand,
+	"bundle":       verifyBundleCommand,
+	"inspect-cert": inspectCertificateCommand,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: ktverify <epoch|proof|chain|bundle|inspect-cert> [flags] <file>")
+
+		return exitUsage
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "ktverify: unknown command %q\n", args[0])
+
+		return exitUsage
+	}
+	flags := flag.NewFlagSet("ktverify "+args[0], flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	jsonOutput := flags.Bool("json", false, "print the result in JSON")
+	res := cmd(flags, args[1:])
+	if res == nil {
+		return exitUsage
+	}
+	res.Command = args[0]
+	if *jsonOutput {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(res); err != nil {
+			fmt.Fprintln(stderr, "ktverify:", err)
+
+			return exitFailure
+		}
+	} else {
+		printResult(stdout, res)
+	}
+	if res.OK {
+		return exitOK
+	}
+
+	return stageExitCodes[res.Stage]
+}
+
+func printResult(out io.Writer, res *result) {
+	for _, transcript := range res.Transcripts {
+		fmt.Fprint(out, transcript.Text())
+	}
+	if info := res.Certificate; info != nil {
+		fmt.Fprintf(out, "common name: %s\nissuer: %s\nserial number: %s\n", info.CommonName, info.Issuer, info.SerialNumber)
+		fmt.Fprintf(out, "not before: %d\nnot after: %d\n", info.NotBefore, info.NotAfter)
+		for _, name := range info.DNSNames {
+			fmt.Fprintf(out, "alt. name: %s\n", name)
+		}
+		for _, sct := range info.SCTs {
+			fmt.Fprintf(out, "SCT: log %s (%s) at %d\n", sct.LogID, sct.Operator, sct.Timestamp)
+		}
+		if claim := info.Claim; claim != nil {
+			fmt.Fprintf(
+				out, "epoch claim: epoch %d of %s, chain hash %s, certificate time %d, name version %d\n",
+				claim.EpochID, claim.BaseDomain, claim.ChainHash, claim.CertificateTime, claim.NameVersion,
+			)
+		} else {
+			fmt.Fprintf(out, "epoch claim: %s\n", info.ClaimError)
+		}
+	}
+	if res.OK {
+		fmt.Fprintln(out, "OK")
+	} else {
+		fmt.Fprintf(out, "FAILED at stage %s: %s\n", res.Stage, res.Error)
+	}
+}
+
+// parseFlags parses the flags, and returns the only positional argument.
+func parseFlags(flags *flag.FlagSet, args []string) (string, bool) {
+	if err := flags.Parse(args); err != nil {
+		return "", false
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(flags.Output(), "%s: expected one file argument\n", flags.Name())
+
+		return "", false
+	}
+
+	return flags.Arg(0), true
+}
+
+func readJSON(path string, value interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return json.Unmarshal(data, value) //nolint:wrapcheck
+}
+
+func verifyEpochCommand(flags *flag.FlagSet, args []string) *result {
+	baseDomain := flags.String("base-domain", "", "base domain of the epoch certificates")
+	currentTime := flags.Int64("time", 0, "Unix time to verify at (default now)")
+	path, ok := parseFlags(flags, args)
+	if !ok {
+		return nil
+	}
+	res := &result{OK: true} //nolint:exhaustruct
+	var epoch ktclient.Epoch
+	if err := readJSON(path, &epoch); err != nil {
+		return res.fail(stageInput, err)
+	}
+	transcript := &ktclient.Transcript{} //nolint:exhaustruct
+	res.Transcripts = append(res.Transcripts, transcript)
+	if _, err := ktclient.VerifyEpochWithTranscript(&epoch, *baseDomain, *currentTime, nil, transcript); err != nil {
+		return res.failTranscript(transcript, err)
+	}
+
+	return res
+}
+
+// proofInput is the content of the file verified by the proof command.
+type proofInput struct {
+	Email                     string
+	Revision                  int
+	SignedKeyList             string
+	MinEpochID                int
+	VRFPublicKey              string
+	RootHash                  string
+	Proof                     *ktclient.InsertionProof
+	SkipEmailCanonicalization bool
+}
+
+func verifyProofCommand(flags *flag.FlagSet, args []string) *result {
+	path, ok := parseFlags(flags, args)
+	if !ok {
+		return nil
+	}
+	res := &result{OK: true} //nolint:exhaustruct
+	var input proofInput
+	if err := readJSON(path, &input); err != nil {
+		return res.fail(stageInput, err)
+	}
+	if input.Proof == nil {
+		return res.fail(stageInput, errors.New("missing proof"))
+	}
+	transcript := &ktclient.Transcript{} //nolint:exhaustruct
+	res.Transcripts = append(res.Transcripts, transcript)
+	err := ktclient.VerifyInsertionProofWithTranscript(
+		input.Email,
+		input.Revision,
+		input.SignedKeyList,
+		input.MinEpochID,
+		input.VRFPublicKey,
+		input.RootHash,
+		input.Proof,
+		&ktclient.ProofOptions{SkipEmailCanonicalization: input.SkipEmailCanonicalization}, //nolint:exhaustruct
+		transcript,
+	)
+	if err != nil {
+		return res.failTranscript(transcript, err)
+	}
+
+	return res
+}
+
+func verifyChainCommand(flags *flag.FlagSet, args []string) *result {
+	baseDomain := flags.String("base-domain", "", "base domain of the epoch certificates")
+	currentTime := flags.Int64("time", 0, "Unix time to verify at (default now)")
+	path, ok := parseFlags(flags, args)
+	if !ok {
+		return nil
+	}
+	res := &result{OK: true} //nolint:exhaustruct
+	var epochs []*ktclient.Epoch
+	if err := readJSON(path, &epochs); err != nil {
+		return res.fail(stageInput, err)
+	}
+	if len(epochs) == 0 {
+		return res.fail(stageInput, errors.New("no epoch"))
+	}
+	for i, epoch := range epochs {
+		if epoch == nil {
+			return res.fail(stageInput, fmt.Errorf("null epoch at index %d", i))
+		}
+		transcript := &ktclient.Transcript{} //nolint:exhaustruct
+		res.Transcripts = append(res.Transcripts, transcript)
+		if _, err := ktclient.VerifyEpochWithTranscript(epoch, *baseDomain, *currentTime, nil, transcript); err != nil {
+			return res.failTranscript(transcript, err)
+		}
+		if i > 0 {
+			if err := ktclient.VerifyEpochLink(epochs[i-1], epoch); err != nil {
+				return res.fail(stageEpochLink, err)
+			}
+		}
+	}
+
+	return res
+}
+
+func verifyBundleCommand(flags *flag.FlagSet, args []string) *result {
+	currentTime := flags.Int64("time", -1, "Unix time to verify at (default the time of the bundle, 0 for now)")
+	path, ok := parseFlags(flags, args)
+	if !ok {
+		return nil
+	}
+	res := &result{OK: true} //nolint:exhaustruct
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return res.fail(stageInput, err)
+	}
+	bundle, err := ktclient.ParseProofBundle(data)
+	if err != nil {
+		return res.fail(stageInput, err)
+	}
+	if *currentTime < 0 {
+		*currentTime = bundle.Time
+	}
+	transcript := &ktclient.Transcript{} //nolint:exhaustruct
+	res.Transcripts = append(res.Transcripts, transcript)
+	if err := ktclient.VerifyBundleWithTranscript(bundle, *currentTime, transcript); err != nil {
+		return res.failTranscript(transcript, err)
+	}
+
+	return res
+}
+
+func inspectCertificateCommand(flags *flag.FlagSet, args []string) *result {
+	path, ok := parseFlags(flags, args)
+	if !ok {
+		return nil
+	}
+	res := &result{OK: true} //nolint:exhaustruct
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return res.fail(stageInput, err)
+	}
+	res.Certificate, err = ktclient.InspectCertificate(string(data))
+	if err != nil {
+		return res.fail(stageCertificate, err)
+	}
+
+	return res
+}
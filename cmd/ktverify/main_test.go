@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ktclient "github.com/ProtonMail/pm-key-transparency-go-client"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTime = "1689062800"
+
+func readTestEpoch(t *testing.T) *ktclient.Epoch {
+	t.Helper()
+	var epoch ktclient.Epoch
+	if err := readJSON("testdata/epoch.json", &epoch); err != nil {
+		t.Fatal(err)
+	}
+
+	return &epoch
+}
+
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func writeTestJSON(t *testing.T, value interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return writeTestFile(t, "input.json", data)
+}
+
+func runTest(args ...string) (int, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+
+	return code, stdout.String() + stderr.String()
+}
+
+func TestEpochCommand(t *testing.T) {
+	t.Parallel()
+	code, output := runTest("epoch", "-base-domain", "dev.proton.wtf", "-time", testTime, "testdata/epoch.json")
+	assert.Equal(t, exitOK, code, output)
+	assert.True(t, strings.HasSuffix(output, "OK\n"), output)
+
+	code, output = runTest("epoch", "-base-domain", "proton.me", "-time", testTime, "testdata/epoch.json")
+	assert.Equal(t, exitCertificate, code, output)
+
+	epoch := readTestEpoch(t)
+	epoch.TreeHash = epoch.PreviousChainHash
+	code, output = runTest("epoch", "-base-domain", "dev.proton.wtf", "-json", writeTestJSON(t, epoch))
+	assert.Equal(t, exitChainHash, code, output)
+	var res result
+	assert.NoError(t, json.Unmarshal([]byte(output), &res))
+	assert.Equal(t, "epoch", res.Command)
+	assert.False(t, res.OK)
+	assert.Equal(t, stageChainHash, res.Stage)
+	assert.Len(t, res.Transcripts, 1)
+}
+
+func TestProofCommand(t *testing.T) {
+	t.Parallel()
+	code, output := runTest("proof", "testdata/proof.json")
+	assert.Equal(t, exitOK, code, output)
+
+	var input proofInput
+	if err := readJSON("testdata/proof.json", &input); err != nil {
+		t.Fatal(err)
+	}
+	input.Revision++
+	code, output = runTest("proof", writeTestJSON(t, &input))
+	assert.Equal(t, exitMerkleProof, code, output)
+
+	input.Email = "alice@proton.me"
+	code, output = runTest("proof", writeTestJSON(t, &input))
+	assert.Equal(t, exitVRF, code, output)
+}
+
+func TestChainCommand(t *testing.T) {
+	t.Parallel()
+	epoch := readTestEpoch(t)
+	code, output := runTest("chain", "-base-domain", "dev.proton.wtf", "-time", testTime, writeTestJSON(t, []*ktclient.Epoch{epoch}))
+	assert.Equal(t, exitOK, code, output)
+
+	code, output = runTest(
+		"chain", "-base-domain", "dev.proton.wtf", "-time", testTime, writeTestJSON(t, []*ktclient.Epoch{epoch, epoch}),
+	)
+	assert.Equal(t, exitEpochLink, code, output)
+
+	code, output = runTest("chain", "-base-domain", "dev.proton.wtf", writeTestJSON(t, []*ktclient.Epoch{}))
+	assert.Equal(t, exitInput, code, output)
+}
+
+func TestBundleCommand(t *testing.T) {
+	t.Parallel()
+	var input proofInput
+	if err := readJSON("testdata/proof.json", &input); err != nil {
+		t.Fatal(err)
+	}
+	bundle := ktclient.NewProofBundle(
+		"dev.proton.wtf", input.Email, input.Revision, input.SignedKeyList, input.MinEpochID,
+		input.VRFPublicKey, readTestEpoch(t), input.Proof, 1_689_062_800,
+	)
+	data, err := bundle.Binary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The epoch verifies, but the proof was not produced in its tree.
+	code, output := runTest("bundle", writeTestFile(t, "bundle.bin", data))
+	assert.Equal(t, exitMerkleProof, code, output)
+	assert.True(t, strings.Contains(output, "2. parse certificates: ok"), output)
+
+	code, output = runTest("bundle", writeTestFile(t, "bundle.bin", data[:10]))
+	assert.Equal(t, exitInput, code, output)
+}
+
+func TestInspectCertificateCommand(t *testing.T) {
+	t.Parallel()
+	code, output := runTest("inspect-cert", "-json", "testdata/epoch_chain.pem")
+	assert.Equal(t, exitOK, code, output)
+	var res result
+	assert.NoError(t, json.Unmarshal([]byte(output), &res))
+	if assert.NotNil(t, res.Certificate) && assert.NotNil(t, res.Certificate.Claim) {
+		assert.Equal(t, 46, res.Certificate.Claim.EpochID)
+		assert.Equal(t, "dev.proton.wtf", res.Certificate.Claim.BaseDomain)
+		assert.Len(t, res.Certificate.SCTs, 2)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	t.Parallel()
+	for _, args := range [][]string{
+		{},
+		{"unknown"},
+		{"epoch"},
+		{"epoch", "-unknown-flag", "testdata/epoch.json"},
+		{"proof", "testdata/proof.json", "testdata/proof.json"},
+	} {
+		code, output := runTest(args...)
+		assert.Equal(t, exitUsage, code, output)
+	}
+	code, output := runTest("proof", "testdata/missing.json")
+	assert.Equal(t, exitInput, code, output)
+}
+
+func TestFailTranscriptStage(t *testing.T) {
+	t.Parallel()
+	transcript := &ktclient.Transcript{ //nolint:exhaustruct
+		Steps: []*ktclient.TranscriptStep{
+			{Name: "chain hash", Outcome: ktclient.TranscriptStepOK},                              //nolint:exhaustruct
+			{Name: "certificate chain", Outcome: ktclient.TranscriptStepFailed, Error: "expired"}, //nolint:exhaustruct
+			{Name: "SCT", Outcome: ktclient.TranscriptStepFailed, Error: "unknown log"},           //nolint:exhaustruct
+		},
+	}
+	res := (&result{}).failTranscript(transcript, errors.New("ktclient: expired")) //nolint:exhaustruct
+	assert.Equal(t, stageCertificate, res.Stage, "a later failed step must not get the blame")
+	res = (&result{}).failTranscript(transcript, errors.New("ktclient: unknown log")) //nolint:exhaustruct
+	assert.Equal(t, stageSCT, res.Stage)
+	res = (&result{}).failTranscript(transcript, errors.New("other")) //nolint:exhaustruct
+	assert.Equal(t, stageCertificate, res.Stage, "the first failed step is used by default")
+}
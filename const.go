@@ -16,8 +16,6 @@ const (
 	obsolescenceProofType = 2
 )
 
-const nameVersion = 1
-
 //go:embed internal/lets_encrypt.crt
 var letsEncryptCertificate string
 
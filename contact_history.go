@@ -0,0 +1,135 @@
+package ktclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// contactHistoryStorageKeyPrefix prefixes the Storage keys under which a
+// ContactHistory saves the history of each email.
+const contactHistoryStorageKeyPrefix = "ktclient/contact-history/"
+
+// VerifiedKeyList records a signed key list of a contact verified in
+// key transparency.
+type VerifiedKeyList struct {
+	Revision int
+	// SKLHash is the hex encoded SHA-256 hash of the signed key list data.
+	SKLHash    string
+	MinEpochID int
+	// EpochID is the latest epoch the list was verified in.
+	EpochID int
+}
+
+// ContactHistory remembers the signed key lists verified for contacts, and
+// checks that the key lists of a contact only move forward: revisions do not
+// go back, a revision always has the same data, and the MinEpochID does not
+// decrease. The history is kept in a Storage, under the canonical email.
+type ContactHistory struct {
+	mutex   sync.Mutex
+	storage Storage
+}
+
+// NewContactHistory creates a ContactHistory kept in the storage.
+func NewContactHistory(storage Storage) *ContactHistory {
+	return &ContactHistory{storage: storage} //nolint:exhaustruct
+}
+
+// Record checks a signed key list of the email, verified in the epoch,
+// against the history of the email, and records it. The history is left
+// unchanged if the check fails, with ErrRevisionRollback,
+// ErrKeyListChanged or ErrMinEpochIDBackwards.
+func (h *ContactHistory) Record(email string, revision int, signedKeyList string, minEpochID, epochID int) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	key, history, err := h.load(email)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(signedKeyList))
+	verified := VerifiedKeyList{
+		Revision:   revision,
+		SKLHash:    hex.EncodeToString(hash[:]),
+		MinEpochID: minEpochID,
+		EpochID:    epochID,
+	}
+	if err := checkKeyListHistory(history, &verified); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("ktclient: %s", email))
+	}
+
+	if len(history) > 0 && history[len(history)-1].Revision == revision {
+		if epochID > history[len(history)-1].EpochID {
+			history[len(history)-1].EpochID = epochID
+		}
+	} else {
+		history = append(history, verified)
+	}
+	data, err := json.Marshal(history)
+	if err != nil {
+		return errors.Wrap(err, "ktclient: encode contact history")
+	}
+
+	return errors.Wrap(h.storage.Set(key, data), "ktclient: save contact history")
+}
+
+// checkKeyListHistory checks a verified key list against the history of its
+// email, sorted by revision.
+func checkKeyListHistory(history []VerifiedKeyList, verified *VerifiedKeyList) error {
+	if len(history) == 0 {
+		return nil
+	}
+	latest := &history[len(history)-1]
+	if verified.Revision < latest.Revision {
+		return fmt.Errorf("%w: revision %d after revision %d", ErrRevisionRollback, verified.Revision, latest.Revision)
+	}
+	if verified.Revision == latest.Revision && verified.SKLHash != latest.SKLHash {
+		return fmt.Errorf("%w: revision %d", ErrKeyListChanged, verified.Revision)
+	}
+	if verified.MinEpochID < latest.MinEpochID {
+		return fmt.Errorf(
+			"%w: %d after %d at revision %d",
+			ErrMinEpochIDBackwards, verified.MinEpochID, latest.MinEpochID, latest.Revision,
+		)
+	}
+	if verified.Revision == latest.Revision && verified.MinEpochID != latest.MinEpochID {
+		return fmt.Errorf("%w: revision %d", ErrKeyListChanged, verified.Revision)
+	}
+
+	return nil
+}
+
+// History returns the signed key lists recorded for the email, by revision.
+func (h *ContactHistory) History(email string) ([]VerifiedKeyList, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	_, history, err := h.load(email)
+
+	return history, err
+}
+
+// load returns the Storage key and the history of the email.
+func (h *ContactHistory) load(email string) (string, []VerifiedKeyList, error) {
+	canonicalEmail, err := CanonicalizeEmail(email)
+	if err != nil {
+		return "", nil, err
+	}
+	key := contactHistoryStorageKeyPrefix + canonicalEmail
+	data, err := h.storage.Get(key)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "ktclient: load contact history")
+	}
+	var history []VerifiedKeyList
+	if data != nil {
+		if err := json.Unmarshal(data, &history); err != nil {
+			return "", nil, errors.Wrap(err, "ktclient: invalid contact history")
+		}
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].Revision < history[j].Revision })
+
+	return key, history, nil
+}
@@ -0,0 +1,47 @@
+package ktclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContactHistory(t *testing.T) {
+	t.Parallel()
+	storage := NewMemoryStorage()
+	history := NewContactHistory(storage)
+	assert.NoError(t, history.Record("Alice@Proton.me", 1, "list 1", 10, 12))
+	assert.NoError(t, history.Record("alice@proton.me", 1, "list 1", 10, 15))
+	assert.NoError(t, history.Record("alice@proton.me", 1, "list 1", 10, 13))
+	assert.NoError(t, history.Record("alice@proton.me", 3, "list 3", 20, 20))
+
+	for name, test := range map[string]struct {
+		revision      int
+		signedKeyList string
+		minEpochID    int
+		err           error
+	}{
+		"rollback":             {2, "list 2", 20, ErrRevisionRollback},
+		"changed list":         {3, "other list 3", 20, ErrKeyListChanged},
+		"changed MinEpochID":   {3, "list 3", 21, ErrKeyListChanged},
+		"MinEpochID backwards": {4, "list 4", 19, ErrMinEpochIDBackwards},
+	} {
+		err := history.Record("alice@proton.me", test.revision, test.signedKeyList, test.minEpochID, 25)
+		assert.True(t, errors.Is(err, test.err), "%s: %v", name, err)
+	}
+
+	// The history survives the tracker, and is left unchanged by rejected lists.
+	verified, err := NewContactHistory(storage).History(" ALICE@proton.me")
+	assert.NoError(t, err)
+	assert.Equal(t, []VerifiedKeyList{
+		{Revision: 1, SKLHash: "ee8322ada4af531910b4d21faba6ac16ea0dfac35014da1e33d72b9d70f98f89", MinEpochID: 10, EpochID: 15},
+		{Revision: 3, SKLHash: "350b47434076a28597869918c09992faa5bace3bb3a8b8af312c975493adfe45", MinEpochID: 20, EpochID: 20},
+	}, verified)
+
+	verified, err = history.History("bob@proton.me")
+	assert.NoError(t, err)
+	assert.Empty(t, verified)
+	assert.NoError(t, history.Record("bob@proton.me", 1, "list 1", 1, 1))
+	assert.Error(t, history.Record("not an email", 1, "list 1", 1, 1))
+}
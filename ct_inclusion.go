@@ -0,0 +1,143 @@
+package ktclient
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/ctutil"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509util"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/rfc6962"
+	"github.com/pkg/errors"
+)
+
+// VerifyCertificateInclusion verifies that the leaf certificate of the PEM
+// encoded chain is included in the CT logs which issued its SCTs: for each
+// SCT whose log is in logs, it checks the log's signed tree head and the
+// Merkle audit path of the certificate, see RFC 6962 section 2.1.1.
+// logs is indexed by base64 log ID. SCTs of other logs are not checked,
+// but at least one SCT must be.
+func VerifyCertificateInclusion(certificateChain string, logs map[string]CTLogProofSource) error {
+	publicKeys, err := parseCTPublicKeys(ctLogs)
+	if err != nil {
+		return err
+	}
+
+	return verifyCertificateInclusion(certificateChain, logs, publicKeys, nil)
+}
+
+// verifyCertificateInclusion verifies the inclusion of the certificate in
+// the logs. If sthStore is not nil, the tree heads used are added to it,
+// so logs must also implement CTLogConsistencySource.
+func verifyCertificateInclusion(
+	certificateChain string,
+	logs map[string]CTLogProofSource,
+	publicKeys map[string]ctPublicKey,
+	sthStore *STHStore,
+) error {
+	cert, rest, err := convertPEMEncodedCertToX509Cert([]byte(certificateChain))
+	if err != nil {
+		return err
+	}
+	issuerPEM, _ := pem.Decode(rest)
+	if issuerPEM == nil {
+		return fmt.Errorf("ktclient: %w: missing issuer certificate", errCert)
+	}
+	issuer, err := x509.ParseCertificate(issuerPEM.Bytes)
+	if err != nil {
+		return errors.Wrap(err, "ktclient: cannot parse cert")
+	}
+	scts, err := x509util.ParseSCTsFromSCTList(&cert.SCTList)
+	if err != nil {
+		return errors.Wrap(err, "ktclient: parse SCTs")
+	}
+	checked := 0
+	for _, sct := range scts {
+		logID := base64.StdEncoding.EncodeToString(sct.LogID.KeyID[:])
+		source, ok := logs[logID]
+		if !ok {
+			continue
+		}
+		key, ok := publicKeys[logID]
+		if !ok {
+			return fmt.Errorf("ktclient: %w: no public key available for log ID %s", errCTLog, logID)
+		}
+		if err := verifySCTInclusion(cert, issuer, sct, logID, source, key, sthStore); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("ktclient: inclusion in log ID %s", logID))
+		}
+		checked++
+	}
+	if checked == 0 {
+		return fmt.Errorf("ktclient: %w: no SCT from the given logs", errCTLog)
+	}
+
+	return nil
+}
+
+func verifySCTInclusion(
+	cert, issuer *x509.Certificate,
+	sct *ct.SignedCertificateTimestamp,
+	logID string,
+	source CTLogProofSource,
+	key ctPublicKey,
+	sthStore *STHStore,
+) error {
+	leafHash, err := ctutil.LeafHash([]*x509.Certificate{cert, issuer}, sct, true)
+	if err != nil {
+		return errors.Wrap(err, "ktclient: CT leaf hash")
+	}
+	sth, err := source.GetSTH()
+	if err != nil {
+		return errors.Wrap(err, "ktclient: get STH")
+	}
+	if err := verifySTHSignature(sth, key); err != nil {
+		return err
+	}
+	if sthStore != nil {
+		consistencySource, ok := source.(CTLogConsistencySource)
+		if !ok {
+			return fmt.Errorf("ktclient: %w: log cannot provide consistency proofs", errCTLog)
+		}
+		if err := sthStore.AddSTH(logID, sth, consistencySource); err != nil {
+			return err
+		}
+	}
+	if sth.Timestamp < sct.Timestamp {
+		return fmt.Errorf("ktclient: %w: STH older than the SCT", errCTLog)
+	}
+	proof, err := source.GetProofByHash(leafHash[:], sth.TreeSize)
+	if err != nil {
+		return errors.Wrap(err, "ktclient: get inclusion proof")
+	}
+	err = merkle.NewLogVerifier(rfc6962.DefaultHasher).VerifyInclusionProof(
+		proof.LeafIndex,
+		int64(sth.TreeSize),
+		proof.AuditPath,
+		sth.SHA256RootHash[:],
+		leafHash[:],
+	)
+	if err != nil {
+		return fmt.Errorf("ktclient: %w: invalid inclusion proof: %v", errCTLog, err) //nolint:errorlint
+	}
+
+	return nil
+}
+
+func verifySTHSignature(sth *ct.SignedTreeHead, key ctPublicKey) error {
+	publicKey, err := ct.PublicKeyFromB64(key.PublicKey)
+	if err != nil {
+		return fmt.Errorf("ktclient: %w: cannot parse public key: %v", errCTLog, err) //nolint:errorlint
+	}
+	verifier, err := ct.NewSignatureVerifier(publicKey)
+	if err != nil {
+		return fmt.Errorf("ktclient: %w: unsupported public key: %v", errCTLog, err) //nolint:errorlint
+	}
+	if err := verifier.VerifySTHSignature(*sth); err != nil {
+		return fmt.Errorf("ktclient: %w: invalid STH signature: %v", errCTLog, err) //nolint:errorlint
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package ktclient
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/google/certificate-transparency-go/x509util"
+	"github.com/stretchr/testify/assert"
+)
+
+// newImpersonatingCTLogs returns memory logs standing in for the logs which
+// issued the SCTs of the test certificate, and the public keys to verify them.
+func newImpersonatingCTLogs(t *testing.T) (map[string]*memoryCTLog, map[string]ctPublicKey) {
+	t.Helper()
+	cert, _, err := convertPEMEncodedCertToX509Cert([]byte(certificateChain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scts, err := x509util.ParseSCTsFromSCTList(&cert.SCTList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := make(map[string]*memoryCTLog)
+	publicKeys := make(map[string]ctPublicKey)
+	for _, sct := range scts {
+		log := newMemoryCTLog(t)
+		log.logID = sct.LogID.KeyID
+		logID := base64.StdEncoding.EncodeToString(sct.LogID.KeyID[:])
+		logs[logID] = log
+		publicKeys[logID] = ctPublicKey{OperatorName: logID, PublicKey: log.PublicKey()}
+	}
+
+	return logs, publicKeys
+}
+
+func proofSources(logs map[string]*memoryCTLog) map[string]CTLogProofSource {
+	sources := make(map[string]CTLogProofSource)
+	for logID, log := range logs {
+		sources[logID] = log
+	}
+
+	return sources
+}
+
+func TestCertificateInclusion(t *testing.T) {
+	t.Parallel()
+	logs, publicKeys := newImpersonatingCTLogs(t)
+	assert.Len(t, logs, 2)
+	for _, log := range logs {
+		for i := 0; i < 5; i++ {
+			_, err := log.AddChain(newTestEpochCertificate(t, testUnrelatedCertName))
+			assert.NoError(t, err)
+		}
+		_, err := log.AddChain(certificateChain)
+		assert.NoError(t, err)
+		_, err = log.AddChain(newTestEpochCertificate(t, testUnrelatedCertName))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, verifyCertificateInclusion(certificateChain, proofSources(logs), publicKeys, nil))
+
+	for logID, log := range logs {
+		single := map[string]CTLogProofSource{logID: log}
+		assert.NoError(t, verifyCertificateInclusion(certificateChain, single, publicKeys, nil))
+	}
+}
+
+func TestCertificateInclusionFailures(t *testing.T) {
+	t.Parallel()
+	logs, publicKeys := newImpersonatingCTLogs(t)
+	for _, log := range logs {
+		_, err := log.AddChain(newTestEpochCertificate(t, testUnrelatedCertName))
+		assert.NoError(t, err)
+	}
+	err := verifyCertificateInclusion(certificateChain, proofSources(logs), publicKeys, nil)
+	assert.True(t, errors.Is(err, errCTLog), "certificate not in the logs")
+
+	for _, log := range logs {
+		_, err := log.AddChain(certificateChain)
+		assert.NoError(t, err)
+	}
+	err = verifyCertificateInclusion(certificateChain, map[string]CTLogProofSource{}, publicKeys, nil)
+	assert.True(t, errors.Is(err, errCTLog), "no log to check")
+
+	otherKeys := make(map[string]ctPublicKey)
+	for logID := range publicKeys {
+		otherKeys[logID] = ctPublicKey{OperatorName: logID, PublicKey: newMemoryCTLog(t).PublicKey()}
+	}
+	err = verifyCertificateInclusion(certificateChain, proofSources(logs), otherKeys, nil)
+	assert.True(t, errors.Is(err, errCTLog), "STH signed by another key")
+
+	err = verifyCertificateInclusion(certificateChain, proofSources(logs), map[string]ctPublicKey{}, nil)
+	assert.True(t, errors.Is(err, errCTLog), "unknown log key")
+}
+
+func TestEpochVerificationWithCTInclusion(t *testing.T) {
+	t.Parallel()
+	epoch := Epoch{
+		EpochID:           46,
+		PreviousChainHash: "9624e880fe4b49b45fc15ca7e16b7ed8a846724a1802b2a2ca9d749770b00f4b",
+		CertificateChain:  certificateChain,
+		CertificateIssuer: 1,
+		TreeHash:          "65e8dd7b133f7a02fe0e249928f7fdf1a21df8a5923234c97e0177f0f56a194c",
+		ChainHash:         "506062a81b4f2ae8aeb2f6dd2d003adac3cd1e84a39d19225a8653d1012cf0d2",
+		CertificateTime:   1_689_062_740,
+	}
+	logs, publicKeys := newImpersonatingCTLogs(t)
+	for _, log := range logs {
+		_, err := log.AddChain(certificateChain)
+		assert.NoError(t, err)
+	}
+	// The memory logs cannot sign tree heads with the keys of the real logs.
+	_, err := VerifyEpochWithOptions(&epoch, "dev.proton.wtf", 1_689_062_740, &EpochOptions{ //nolint:exhaustruct
+		CTLogs: proofSources(logs),
+	})
+	assert.True(t, errors.Is(err, errCTLog))
+
+	// They can with their own keys.
+	ctLogPublicKeys := make(map[string]string)
+	for logID, key := range publicKeys {
+		ctLogPublicKeys[logID] = key.PublicKey
+	}
+	options := &EpochOptions{ //nolint:exhaustruct
+		CTLogs:          proofSources(logs),
+		CTLogPublicKeys: ctLogPublicKeys,
+	}
+	notBefore, err := VerifyEpochWithOptions(&epoch, "dev.proton.wtf", 1_689_062_740, options)
+	assert.NoError(t, err)
+	assert.NotZero(t, notBefore)
+
+	// The certificate must still be in the logs.
+	options.CTLogs = proofSources(map[string]*memoryCTLog{})
+	for logID := range logs {
+		options.CTLogs[logID] = newForkedCTLog(t, logs[logID])
+	}
+	_, err = VerifyEpochWithOptions(&epoch, "dev.proton.wtf", 1_689_062_740, options)
+	assert.True(t, errors.Is(err, errCTLog), err)
+}
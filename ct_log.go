@@ -0,0 +1,29 @@
+package ktclient
+
+import (
+	ct "github.com/google/certificate-transparency-go"
+)
+
+// CTLogEntry is a certificate chain logged in a Certificate Transparency log.
+type CTLogEntry struct {
+	Index            int64
+	CertificateChain string
+}
+
+// CTLogEntrySource reads the entries of a Certificate Transparency log.
+// Applications implement it on top of their CT log client.
+type CTLogEntrySource interface {
+	// GetEntries returns the entries from index start onwards, at most count
+	// of them. It returns fewer entries when it reaches the end of the log.
+	GetEntries(start int64, count int) ([]CTLogEntry, error)
+}
+
+// CTLogProofSource fetches signed tree heads and inclusion proofs
+// from a Certificate Transparency log, see RFC 6962 section 4.
+type CTLogProofSource interface {
+	// GetSTH returns the latest signed tree head of the log.
+	GetSTH() (*ct.SignedTreeHead, error)
+	// GetProofByHash returns the audit path of the leaf with the given
+	// Merkle leaf hash, in the tree of the given size.
+	GetProofByHash(leafHash []byte, treeSize uint64) (*ct.GetProofByHashResponse, error)
+}
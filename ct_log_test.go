@@ -0,0 +1,202 @@
+package ktclient
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509util"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/rfc6962"
+)
+
+// memoryCTLog is a Certificate Transparency log kept in memory,
+// standing in for the real logs.
+type memoryCTLog struct {
+	mutex       sync.Mutex
+	entries     []CTLogEntry
+	tree        *merkle.InMemoryMerkleTree
+	leafIndices map[[sha256.Size]byte]int64
+	signer      *ecdsa.PrivateKey
+	publicKey   string
+	logID       [sha256.Size]byte
+}
+
+// newMemoryCTLog creates an empty memoryCTLog,
+// which signs its tree heads with a new ECDSA P-256 key.
+func newMemoryCTLog(t *testing.T) *memoryCTLog {
+	t.Helper()
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&signer.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &memoryCTLog{ //nolint:exhaustruct
+		tree:        merkle.NewInMemoryMerkleTree(rfc6962.DefaultHasher),
+		leafIndices: make(map[[sha256.Size]byte]int64),
+		signer:      signer,
+		publicKey:   base64.StdEncoding.EncodeToString(publicKey),
+		logID:       sha256.Sum256(publicKey),
+	}
+}
+
+// newForkedCTLog returns an empty log with the same identity as log,
+// to build a view of the log diverging from it.
+func newForkedCTLog(t *testing.T, log *memoryCTLog) *memoryCTLog {
+	t.Helper()
+	fork := newMemoryCTLog(t)
+	fork.signer = log.signer
+	fork.publicKey = log.publicKey
+	fork.logID = log.logID
+
+	return fork
+}
+
+// LogID returns the base64 encoded ID of the log, as in CT log lists.
+func (l *memoryCTLog) LogID() string {
+	return base64.StdEncoding.EncodeToString(l.logID[:])
+}
+
+// PublicKey returns the base64 encoded DER public key of the log, as in CT log lists.
+func (l *memoryCTLog) PublicKey() string {
+	return l.publicKey
+}
+
+// AddChain logs a PEM encoded certificate chain and returns its index.
+// A certificate embedding an SCT of this log is logged as the matching
+// precertificate entry, any other certificate as an X.509 entry.
+func (l *memoryCTLog) AddChain(certificateChain string) (int64, error) {
+	leaf, err := l.merkleTreeLeaf(certificateChain)
+	if err != nil {
+		return 0, err
+	}
+	leafData, err := tls.Marshal(*leaf)
+	if err != nil {
+		return 0, fmt.Errorf("ktclient: encode CT log leaf: %w", err)
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	index := int64(len(l.entries))
+	l.entries = append(l.entries, CTLogEntry{
+		Index:            index,
+		CertificateChain: certificateChain,
+	})
+	_, treeEntry := l.tree.AddLeaf(leafData)
+	var leafHash [sha256.Size]byte
+	copy(leafHash[:], treeEntry.Hash())
+	l.leafIndices[leafHash] = index
+
+	return index, nil
+}
+
+func (l *memoryCTLog) merkleTreeLeaf(certificateChain string) (*ct.MerkleTreeLeaf, error) {
+	var chain []*x509.Certificate
+	rest := []byte(certificateChain)
+	for len(bytes.TrimSpace(rest)) > 0 {
+		cert, next, err := convertPEMEncodedCertToX509Cert(rest)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, cert)
+		rest = next
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("ktclient: %w: empty certificate chain", errCTLog)
+	}
+	scts, err := x509util.ParseSCTsFromSCTList(&chain[0].SCTList)
+	if err == nil && len(chain) > 1 {
+		for _, sct := range scts {
+			if sct.LogID.KeyID == l.logID {
+				return ct.MerkleTreeLeafForEmbeddedSCT(chain, sct.Timestamp)
+			}
+		}
+	}
+
+	return ct.CreateX509MerkleTreeLeaf(ct.ASN1Cert{Data: chain[0].Raw}, uint64(time.Now().UnixMilli())), nil
+}
+
+// GetEntries implements CTLogEntrySource.
+func (l *memoryCTLog) GetEntries(start int64, count int) ([]CTLogEntry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if start < 0 || count < 0 || start > int64(len(l.entries)) {
+		return nil, fmt.Errorf("ktclient: %w: invalid entry range", errCTLog)
+	}
+	end := start + int64(count)
+	if end > int64(len(l.entries)) {
+		end = int64(len(l.entries))
+	}
+
+	return append([]CTLogEntry(nil), l.entries[start:end]...), nil
+}
+
+// GetSTH implements CTLogProofSource.
+func (l *memoryCTLog) GetSTH() (*ct.SignedTreeHead, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	sth := ct.SignedTreeHead{ //nolint:exhaustruct
+		Version:   ct.V1,
+		TreeSize:  uint64(l.tree.LeafCount()),
+		Timestamp: uint64(time.Now().UnixMilli()),
+		LogID:     l.logID,
+	}
+	copy(sth.SHA256RootHash[:], l.tree.CurrentRoot().Hash())
+	signatureInput, err := ct.SerializeSTHSignatureInput(sth)
+	if err != nil {
+		return nil, fmt.Errorf("ktclient: encode STH: %w", err)
+	}
+	signature, err := tls.CreateSignature(*l.signer, tls.SHA256, signatureInput)
+	if err != nil {
+		return nil, fmt.Errorf("ktclient: sign STH: %w", err)
+	}
+	sth.TreeHeadSignature = ct.DigitallySigned(signature)
+
+	return &sth, nil
+}
+
+// GetProofByHash implements CTLogProofSource.
+func (l *memoryCTLog) GetProofByHash(leafHash []byte, treeSize uint64) (*ct.GetProofByHashResponse, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	var key [sha256.Size]byte
+	copy(key[:], leafHash)
+	index, ok := l.leafIndices[key]
+	if !ok || len(leafHash) != sha256.Size || uint64(index) >= treeSize || treeSize > uint64(l.tree.LeafCount()) {
+		return nil, fmt.Errorf("ktclient: %w: no leaf with hash %x in tree of size %d", errCTLog, leafHash, treeSize)
+	}
+	response := &ct.GetProofByHashResponse{LeafIndex: index, AuditPath: [][]byte{}}
+	for _, node := range l.tree.PathToRootAtSnapshot(index+1, int64(treeSize)) {
+		response.AuditPath = append(response.AuditPath, node.Value.Hash())
+	}
+
+	return response, nil
+}
+
+// GetSTHConsistency implements CTLogConsistencySource.
+func (l *memoryCTLog) GetSTHConsistency(first, second uint64) ([][]byte, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if first > second || second > uint64(l.tree.LeafCount()) {
+		return nil, fmt.Errorf("ktclient: %w: invalid tree sizes %d and %d", errCTLog, first, second)
+	}
+	proof := [][]byte{}
+	for _, node := range l.tree.SnapshotConsistency(int64(first), int64(second)) {
+		proof = append(proof, node.Value.Hash())
+	}
+
+	return proof, nil
+}
@@ -0,0 +1,37 @@
+package ktclient
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// emailDomainProfile maps domains as for a DNS lookup and also rejects
+// empty labels and over-long names.
+var emailDomainProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.VerifyDNSLength(true),
+)
+
+// CanonicalizeEmail returns the form of the email address the server feeds
+// into the VRF: surrounding whitespace is trimmed, the address is NFC
+// normalized, the local part is case folded and the domain is converted to
+// its lower case IDNA (punycode) representation.
+func CanonicalizeEmail(email string) (string, error) {
+	trimmed := norm.NFC.String(strings.TrimSpace(email))
+	at := strings.LastIndex(trimmed, "@")
+	if at <= 0 || at == len(trimmed)-1 {
+		return "", fmt.Errorf("ktclient: %w: missing local part or domain", errInvalidEmail)
+	}
+	localPart := norm.NFC.String(cases.Fold().String(trimmed[:at]))
+	domain, err := emailDomainProfile.ToASCII(trimmed[at+1:])
+	if err != nil {
+		return "", fmt.Errorf("ktclient: %w: invalid domain: %v", errInvalidEmail, err) //nolint:errorlint
+	}
+
+	return localPart + "@" + domain, nil
+}
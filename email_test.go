@@ -0,0 +1,86 @@
+package ktclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalizeEmail(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		email    string
+		expected string
+	}{
+		{"already canonical", "alice@proton.me", "alice@proton.me"},
+		{"upper case local part", "Alice@proton.me", "alice@proton.me"},
+		{"upper case domain", "alice@Proton.ME", "alice@proton.me"},
+		{"surrounding whitespace", " \talice@proton.me\n", "alice@proton.me"},
+		{"sharp s is folded", "Straße@proton.me", "strasse@proton.me"},
+		{"final sigma is folded", "ΟΔΟΣ@proton.me", "οδοσ@proton.me"},
+		{"decomposed accent is composed", "café@proton.me", "café@proton.me"},
+		{"composed accent is kept", "café@proton.me", "café@proton.me"},
+		{"unicode domain", "alice@bücher.example", "alice@xn--bcher-kva.example"},
+		{"upper case unicode domain", "alice@BÜCHER.example", "alice@xn--bcher-kva.example"},
+		{"punycode domain", "alice@xn--bcher-kva.example", "alice@xn--bcher-kva.example"},
+		{"decomposed unicode domain", "alice@bücher.example", "alice@xn--bcher-kva.example"},
+		{"fullwidth domain", "alice@ｐｒｏｔｏｎ.me", "alice@proton.me"},
+		{"plus addressing is kept", "Alice+Tag@proton.me", "alice+tag@proton.me"},
+		{"dots are kept", "a.li.ce@proton.me", "a.li.ce@proton.me"},
+		{"quoted local part with at", `"a@b"@proton.me`, `"a@b"@proton.me`},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			canonical, err := CanonicalizeEmail(testCase.email)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, canonical)
+		})
+	}
+}
+
+func TestCanonicalizeInvalidEmail(t *testing.T) {
+	t.Parallel()
+	for _, email := range []string{"", "   ", "alice", "@proton.me", "alice@", "alice@proton..me", "alice@a‏b.me"} {
+		_, err := CanonicalizeEmail(email)
+		if !errors.Is(err, errInvalidEmail) {
+			t.Fatalf("Expected invalid email error for %q, got %v", email, err)
+		}
+	}
+}
+
+func TestInsertionProofCanonicalizesEmail(t *testing.T) {
+	t.Parallel()
+	email := "  KTTests@Willis.Proton.Black "
+	testData := newTestSKLProofData()
+	testData.email = email
+	proof, err := testData.getProof()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = VerifyInsertionProof(
+		testData.email,
+		testData.revision,
+		testData.signedKeyList,
+		testData.minEpochID,
+		testVRFPublicKey,
+		testData.rootHash,
+		proof,
+	)
+	assert.NoError(t, err)
+
+	err = VerifyInsertionProofWithOptions(
+		testData.email,
+		testData.revision,
+		testData.signedKeyList,
+		testData.minEpochID,
+		testVRFPublicKey,
+		testData.rootHash,
+		proof,
+		&ProofOptions{SkipEmailCanonicalization: true},
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,43 @@
+package ktclient
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+)
+
+// VerifyEpochLink checks that epoch directly follows previous in the
+// chain of epochs: its ID is the next one, and its previous chain hash is
+// the chain hash of previous. Both epochs should be verified separately,
+// with VerifyEpoch.
+func VerifyEpochLink(previous, epoch *Epoch) error {
+	if epoch.EpochID != previous.EpochID+1 {
+		return fmt.Errorf(
+			"ktclient: %w: epoch %d does not follow epoch %d",
+			errEpochLink, epoch.EpochID, previous.EpochID,
+		)
+	}
+	if !equalHashHex(epoch.PreviousChainHash, previous.ChainHash) {
+		return fmt.Errorf(
+			"ktclient: %w: previous chain hash of epoch %d is not the chain hash of epoch %d",
+			errEpochLink, epoch.EpochID, previous.EpochID,
+		)
+	}
+
+	return nil
+}
+
+// equalHashHex tells whether two hex encoded hashes are the same hash,
+// whatever the case of their hex digits. Invalid encodings are never equal.
+func equalHashHex(a, b string) bool {
+	aBytes, err := hex.DecodeString(a)
+	if err != nil {
+		return false
+	}
+	bBytes, err := hex.DecodeString(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(aBytes, bBytes)
+}
@@ -0,0 +1,29 @@
+package ktclient
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyEpochLink(t *testing.T) {
+	t.Parallel()
+	previous := newTestEpoch()
+	next := &Epoch{ //nolint:exhaustruct
+		EpochID:           previous.EpochID + 1,
+		PreviousChainHash: previous.ChainHash,
+	}
+	assert.NoError(t, VerifyEpochLink(previous, next))
+	next.PreviousChainHash = strings.ToUpper(previous.ChainHash)
+	assert.NoError(t, VerifyEpochLink(previous, next))
+
+	next.EpochID++
+	assert.True(t, errors.Is(VerifyEpochLink(previous, next), errEpochLink))
+	next.EpochID--
+	next.PreviousChainHash = previous.PreviousChainHash
+	assert.True(t, errors.Is(VerifyEpochLink(previous, next), errEpochLink))
+	next.PreviousChainHash = "not hex"
+	assert.True(t, errors.Is(VerifyEpochLink(previous, next), errEpochLink))
+}
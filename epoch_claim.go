@@ -0,0 +1,116 @@
+package ktclient
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+// epochNameLabels is the number of labels of an epoch name before the base domain.
+const epochNameLabels = 5
+
+// EpochClaim is the epoch attested by an epoch certificate,
+// as encoded in one of its alternate names.
+type EpochClaim struct {
+	ChainHash       string
+	CertificateTime int64
+	EpochID         int
+	NameVersion     int
+	BaseDomain      string
+}
+
+// EpochClaimFromCertificate returns the epoch claimed by the first
+// certificate of the PEM encoded chain. The certificate is not verified:
+// the claim must still be checked with VerifyEpoch before being trusted.
+func EpochClaimFromCertificate(certificateChain string) (*EpochClaim, error) {
+	cert, _, err := convertPEMEncodedCertToX509Cert([]byte(certificateChain))
+	if err != nil {
+		return nil, err
+	}
+
+	return epochClaimFromX509(cert)
+}
+
+func epochClaimFromX509(cert *x509.Certificate) (*EpochClaim, error) {
+	var claim *EpochClaim
+	for _, altName := range cert.DNSNames {
+		parsed, ok := parseEpochName(altName)
+		if !ok {
+			continue
+		}
+		if claim != nil && *claim != *parsed {
+			return nil, fmt.Errorf("ktclient: %w: several epochs claimed in alt. names", errCert)
+		}
+		claim = parsed
+	}
+	if claim == nil {
+		return nil, fmt.Errorf("ktclient: %w: no epoch claimed in alt. names", errCert)
+	}
+
+	return claim, nil
+}
+
+// epochName returns the alternate name an epoch certificate has for the epoch:
+// <hash[:32]>.<hash[32:]>.<time>.<epochID>.<version>.<baseDomain>.
+func epochName(
+	chainHash []byte,
+	certificateTime int64,
+	epochID int,
+	nameVersion int,
+	baseDomain string,
+) string {
+	hashStr := fmt.Sprintf("%x", chainHash)
+
+	return fmt.Sprintf(
+		"%s.%s.%d.%d.%d.%s",
+		hashStr[:32],
+		hashStr[32:],
+		certificateTime,
+		epochID,
+		nameVersion,
+		baseDomain,
+	)
+}
+
+// parseEpochName is the inverse of epochName. It only accepts names
+// in the exact form epochName produces.
+func parseEpochName(name string) (*EpochClaim, bool) {
+	labels := strings.SplitN(name, ".", epochNameLabels+1)
+	if len(labels) != epochNameLabels+1 || labels[epochNameLabels] == "" {
+		return nil, false
+	}
+	if len(labels[0]) != 32 || len(labels[1]) != 32 {
+		return nil, false
+	}
+	chainHash, err := hex.DecodeString(labels[0] + labels[1])
+	if err != nil {
+		return nil, false
+	}
+	certificateTime, err := strconv.ParseInt(labels[2], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	epochID, err := strconv.Atoi(labels[3])
+	if err != nil {
+		return nil, false
+	}
+	nameVersion, err := strconv.Atoi(labels[4])
+	if err != nil {
+		return nil, false
+	}
+	claim := &EpochClaim{
+		ChainHash:       hex.EncodeToString(chainHash),
+		CertificateTime: certificateTime,
+		EpochID:         epochID,
+		NameVersion:     nameVersion,
+		BaseDomain:      labels[epochNameLabels],
+	}
+	if epochName(chainHash, certificateTime, epochID, nameVersion, claim.BaseDomain) != name {
+		return nil, false
+	}
+
+	return claim, true
+}
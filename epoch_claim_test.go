@@ -0,0 +1,63 @@
+package ktclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEpochClaimFromCertificate(t *testing.T) {
+	t.Parallel()
+	claim, err := EpochClaimFromCertificate(certificateChain)
+	assert.NoError(t, err)
+	assert.Equal(t, &EpochClaim{
+		ChainHash:       "506062a81b4f2ae8aeb2f6dd2d003adac3cd1e84a39d19225a8653d1012cf0d2",
+		CertificateTime: 1_689_062_740,
+		EpochID:         46,
+		NameVersion:     1,
+		BaseDomain:      "dev.proton.wtf",
+	}, claim)
+}
+
+func TestEpochClaimWithoutEpochName(t *testing.T) {
+	t.Parallel()
+	_, err := EpochClaimFromCertificate(certificateChain[len("-----BEGIN CERTIFICATE-----"):])
+	assert.Error(t, err)
+
+	cert, _, err := convertPEMEncodedCertToX509Cert([]byte(certificateChain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert.DNSNames = []string{"epoch.46.1.dev.proton.wtf"}
+	_, err = epochClaimFromX509(cert)
+	assert.True(t, errors.Is(err, errCert))
+
+	cert.DNSNames = []string{
+		"506062a81b4f2ae8aeb2f6dd2d003ada.c3cd1e84a39d19225a8653d1012cf0d2.1689062740.46.1.dev.proton.wtf",
+		"506062a81b4f2ae8aeb2f6dd2d003ada.c3cd1e84a39d19225a8653d1012cf0d2.1689062740.47.1.dev.proton.wtf",
+	}
+	_, err = epochClaimFromX509(cert)
+	assert.True(t, errors.Is(err, errCert))
+}
+
+func TestParseEpochName(t *testing.T) {
+	t.Parallel()
+	valid := "506062a81b4f2ae8aeb2f6dd2d003ada.c3cd1e84a39d19225a8653d1012cf0d2.1689062740.46.1.dev.proton.wtf"
+	claim, ok := parseEpochName(valid)
+	assert.True(t, ok)
+	assert.Equal(t, 46, claim.EpochID)
+	for _, name := range []string{
+		"epoch.46.1.dev.proton.wtf",
+		"506062a81b4f2ae8aeb2f6dd2d003ada.c3cd1e84a39d19225a8653d1012cf0d2.1689062740.46.1",
+		"506062a81b4f2ae8aeb2f6dd2d003ada.c3cd1e84a39d19225a8653d1012cf0d2.1689062740.46.1.",
+		"506062A81B4F2AE8AEB2F6DD2D003ADA.c3cd1e84a39d19225a8653d1012cf0d2.1689062740.46.1.dev.proton.wtf",
+		"506062a81b4f2ae8aeb2f6dd2d003ad.c3cd1e84a39d19225a8653d1012cf0d2.1689062740.46.1.dev.proton.wtf",
+		"506062a81b4f2ae8aeb2f6dd2d003ada.c3cd1e84a39d19225a8653d1012cf0d2.01689062740.46.1.dev.proton.wtf",
+		"506062a81b4f2ae8aeb2f6dd2d003ada.c3cd1e84a39d19225a8653d1012cf0d2.1689062740.+46.1.dev.proton.wtf",
+		"506062a81b4f2ae8aeb2f6dd2d003ada.c3cd1e84a39d19225a8653d1012cf0d2.1689062740.46.x.dev.proton.wtf",
+	} {
+		_, ok := parseEpochName(name)
+		assert.False(t, ok, name)
+	}
+}
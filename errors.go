@@ -6,10 +6,49 @@ import (
 
 // Static errors.
 var (
-	errCert                = errors.New("TLS certificate")
-	errIntegrity           = errors.New("integrity")
-	errSCT                 = errors.New("SCT")
-	errMerkleProof         = errors.New("MerkleTree proof")
-	errVRFProof            = errors.New("VRF proof")
-	errInvalidNeighbourKey = errors.New("ktclient: invalid new key")
+	errCert                   = errors.New("TLS certificate")
+	errIntegrity              = errors.New("integrity")
+	errSCT                    = errors.New("SCT")
+	errMerkleProof            = errors.New("MerkleTree proof")
+	errVRFProof               = errors.New("VRF proof")
+	errVRFKey                 = errors.New("VRF key")
+	errTreeVersion            = errors.New("unknown tree version")
+	errHashSuite              = errors.New("hash suite")
+	errCTLog                  = errors.New("CT log")
+	errSTHConsistency         = errors.New("CT log fork")
+	errSigningKey             = errors.New("epoch signature")
+	errWitness                = errors.New("witness cosignature")
+	errCheckpoint             = errors.New("checkpoint")
+	errRevocation             = errors.New("revocation status")
+	errRevoked                = errors.New("certificate revoked")
+	errNoRevocationInfo       = errors.New("no revocation information")
+	errFreshness              = errors.New("epoch freshness")
+	errProofBundle            = errors.New("proof bundle")
+	errEpochLink              = errors.New("epoch chain")
+	errEpochFork              = errors.New("epoch fork")
+	errEpochGap               = errors.New("epoch gap")
+	errTreeReplay             = errors.New("tree replay")
+	errSignedKeyList          = errors.New("signed key list")
+	errKeyMismatch            = errors.New("keys do not match the signed key list")
+	errOpenPGPKey             = errors.New("OpenPGP key")
+	errSignedKeyListSignature = errors.New("signed key list signature")
+	errKeyLookup              = errors.New("key lookup")
+	errInclusionDelay         = errors.New("signed key list inclusion delay")
+	errInvalidNeighbourKey    = errors.New("ktclient: invalid new key")
+	errInvalidEmail           = errors.New("invalid email address")
+)
+
+// ErrStaleEpoch is returned when an epoch is valid but older than the
+// freshness policy allows: the application should fetch a newer epoch.
+var ErrStaleEpoch = errors.New("stale epoch")
+
+// Errors of a ContactHistory, for signed key lists of a contact going back
+// in time: the application should not trust the keys.
+var (
+	// ErrRevisionRollback is returned for a revision older than a revision already verified.
+	ErrRevisionRollback = errors.New("signed key list revision rollback")
+	// ErrKeyListChanged is returned for a revision verified before with other data or MinEpochID.
+	ErrKeyListChanged = errors.New("signed key list changed for the same revision")
+	// ErrMinEpochIDBackwards is returned for a MinEpochID smaller than the one of a previous revision.
+	ErrMinEpochIDBackwards = errors.New("signed key list MinEpochID moved backwards")
 )
@@ -0,0 +1,70 @@
+package ktclient
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509util"
+)
+
+// FreshnessPolicy bounds the age of the epochs, and how their times
+// relate to the clock and to their certificate. A zero MaxEpochAge or
+// MaxCertificateDelay disables the corresponding check.
+type FreshnessPolicy struct {
+	// MaxEpochAge is the maximum time between the CertificateTime
+	// of the epoch and the current time.
+	MaxEpochAge time.Duration
+	// MaxCertificateDelay is the maximum time between the CertificateTime
+	// of the epoch and the NotBefore of its certificate, in either order.
+	// The CertificateTime must also be before the NotAfter.
+	MaxCertificateDelay time.Duration
+	// MaxClockSkew is how far in the future the CertificateTime and the
+	// SCT timestamps may be, to tolerate clocks running late.
+	MaxClockSkew time.Duration
+}
+
+// verifyEpochAge checks that the epoch is not from the future nor stale.
+func (p *FreshnessPolicy) verifyEpochAge(epoch *Epoch, currentTime time.Time) error {
+	certificateTime := time.Unix(epoch.CertificateTime, 0)
+	if certificateTime.After(currentTime.Add(p.MaxClockSkew)) {
+		return fmt.Errorf("ktclient: %w: certificate time %v in the future", errFreshness, certificateTime)
+	}
+	if p.MaxEpochAge > 0 && currentTime.Sub(certificateTime) > p.MaxEpochAge {
+		return fmt.Errorf("ktclient: %w: epoch %d issued at %v", ErrStaleEpoch, epoch.EpochID, certificateTime)
+	}
+
+	return nil
+}
+
+// verifyCertificateTimes checks the CertificateTime of the epoch against
+// the validity of its certificate, and the SCTs of the certificate
+// against the clock.
+func (p *FreshnessPolicy) verifyCertificateTimes(epoch *Epoch, cert *x509.Certificate, currentTime time.Time) error {
+	certificateTime := time.Unix(epoch.CertificateTime, 0)
+	if p.MaxCertificateDelay > 0 {
+		delay := certificateTime.Sub(cert.NotBefore)
+		if delay > p.MaxCertificateDelay || -delay > p.MaxCertificateDelay {
+			return fmt.Errorf(
+				"ktclient: %w: certificate time %v too far from NotBefore %v",
+				errFreshness, certificateTime, cert.NotBefore,
+			)
+		}
+		if certificateTime.After(cert.NotAfter) {
+			return fmt.Errorf("ktclient: %w: certificate time %v after NotAfter", errFreshness, certificateTime)
+		}
+	}
+	scts, err := x509util.ParseSCTsFromSCTList(&cert.SCTList)
+	if err != nil {
+		return fmt.Errorf("ktclient: %w: cannot parse SCTs: %v", errSCT, err) //nolint:errorlint
+	}
+	latest := currentTime.Add(p.MaxClockSkew)
+	for _, sct := range scts {
+		timestamp := time.UnixMilli(int64(sct.Timestamp))
+		if timestamp.After(latest) {
+			return fmt.Errorf("ktclient: %w: SCT timestamp %v in the future", errFreshness, timestamp)
+		}
+	}
+
+	return nil
+}
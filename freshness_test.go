@@ -0,0 +1,60 @@
+package ktclient
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEpochFreshness(t *testing.T) {
+	t.Parallel()
+	const certificateTime = 1_689_062_740
+	for name, testCase := range map[string]struct {
+		policy          FreshnessPolicy
+		currentUnixTime int64
+		expected        error
+	}{
+		"fresh":                {FreshnessPolicy{MaxEpochAge: time.Hour}, certificateTime + 60, nil},
+		"no maximum age":       {FreshnessPolicy{}, certificateTime + 86_400, nil},
+		"stale":                {FreshnessPolicy{MaxEpochAge: time.Hour}, certificateTime + 3_601, ErrStaleEpoch},
+		"future epoch":         {FreshnessPolicy{}, certificateTime - 60, errFreshness},
+		"tolerated clock skew": {FreshnessPolicy{MaxClockSkew: 5 * time.Minute}, certificateTime - 60, nil},
+		"certificate delay":    {FreshnessPolicy{MaxCertificateDelay: 9 * time.Hour}, certificateTime + 60, nil},
+		"late certificate time": {
+			FreshnessPolicy{MaxCertificateDelay: time.Hour}, certificateTime + 60, errFreshness,
+		},
+		// The SCT was issued 21 seconds after the certificate time.
+		"future SCT": {FreshnessPolicy{}, certificateTime + 10, errFreshness},
+	} {
+		policy := testCase.policy
+		options := &EpochOptions{Freshness: &policy} //nolint:exhaustruct
+		_, err := VerifyEpochWithOptions(newTestEpoch(), "dev.proton.wtf", testCase.currentUnixTime, options)
+		if testCase.expected == nil {
+			assert.NoError(t, err, name)
+		} else {
+			assert.True(t, errors.Is(err, testCase.expected), "%s: %v", name, err)
+		}
+	}
+}
+
+func TestStaleSignedEpoch(t *testing.T) {
+	t.Parallel()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	signingKey, err := ParseEpochSigningKey(encodeTestPublicKey(t, publicKey))
+	assert.NoError(t, err)
+	epoch := newTestSignedEpoch()
+	epoch.Signature = base64.StdEncoding.EncodeToString(
+		ed25519.Sign(privateKey, []byte(signedEpochContext+testSignedEpochName)),
+	)
+	options := &EpochOptions{Freshness: &FreshnessPolicy{MaxEpochAge: time.Minute}} //nolint:exhaustruct
+	_, err = VerifySignedEpoch(epoch, "kt.internal", epoch.CertificateTime+30, signingKey, options)
+	assert.NoError(t, err)
+	_, err = VerifySignedEpoch(epoch, "kt.internal", epoch.CertificateTime+3_600, signingKey, options)
+	assert.True(t, errors.Is(err, ErrStaleEpoch))
+}
@@ -0,0 +1,99 @@
+package ktclient
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// DefaultMaxInclusionDelay is the MaxInclusionDelay of a nil *GracePeriodPolicy.
+const DefaultMaxInclusionDelay = 24 * time.Hour
+
+// DefaultMaxClockSkew is the MaxClockSkew of a nil *GracePeriodPolicy.
+const DefaultMaxClockSkew = 10 * time.Minute
+
+// GracePeriodPolicy tells how long a new signed key list may be missing
+// from key transparency: it is only inserted in the tree of the next epochs.
+// A nil *GracePeriodPolicy selects DefaultMaxInclusionDelay and DefaultMaxClockSkew.
+type GracePeriodPolicy struct {
+	// MaxInclusionDelay is the maximum time between the creation of a signed
+	// key list and the CertificateTime of an epoch not including it yet.
+	MaxInclusionDelay time.Duration
+	// MaxClockSkew is how far in the future the creation of a signed key
+	// list may be, to tolerate the clock of its signer running ahead.
+	MaxClockSkew time.Duration
+}
+
+// CheckPendingInclusion tells whether the signed key list signed by
+// signature is pending inclusion in key transparency, given the latest
+// epoch, which must be verified, at currentUnixTime.
+//
+// It returns false if the list should be in the tree of the epoch: its
+// minEpochID is set and not after the epoch, so its insertion proof must
+// verify. It returns true if the list is not in the tree yet, and was
+// created less than MaxInclusionDelay before the CertificateTime of the
+// epoch and before the current time. It fails once the list waited longer,
+// also when no new epoch is published, and for lists created after the
+// current time plus MaxClockSkew, which would otherwise stay pending forever.
+func (p *GracePeriodPolicy) CheckPendingInclusion(
+	signature string,
+	minEpochID int,
+	epoch *Epoch,
+	currentUnixTime int64,
+) (bool, error) {
+	if minEpochID != 0 && minEpochID <= epoch.EpochID {
+		return false, nil
+	}
+	creationTime, err := signatureCreationTime(signature)
+	if err != nil {
+		return false, err
+	}
+	maxInclusionDelay, maxClockSkew := DefaultMaxInclusionDelay, DefaultMaxClockSkew
+	if p != nil {
+		maxInclusionDelay, maxClockSkew = p.MaxInclusionDelay, p.MaxClockSkew
+	}
+	currentTime := time.Unix(currentUnixTime, 0)
+	if creationTime.After(currentTime.Add(maxClockSkew)) {
+		return false, fmt.Errorf(
+			"ktclient: %w: signed key list created at %v, in the future at %v",
+			errInclusionDelay, creationTime, currentTime,
+		)
+	}
+	certificateTime := time.Unix(epoch.CertificateTime, 0)
+	if certificateTime.Sub(creationTime) > maxInclusionDelay {
+		return false, fmt.Errorf(
+			"ktclient: %w: signed key list created at %v is not in epoch %d issued at %v",
+			errInclusionDelay, creationTime, epoch.EpochID, certificateTime,
+		)
+	}
+	if currentTime.Sub(creationTime) > maxInclusionDelay {
+		return false, fmt.Errorf(
+			"ktclient: %w: signed key list created at %v is not in epoch %d at %v",
+			errInclusionDelay, creationTime, epoch.EpochID, currentTime,
+		)
+	}
+
+	return true, nil
+}
+
+// signatureCreationTime returns the creation time of an armored detached
+// OpenPGP signature.
+func signatureCreationTime(signature string) (time.Time, error) {
+	block, err := armor.Decode(strings.NewReader(signature))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ktclient: %w: %v", errSignedKeyListSignature, err) //nolint:errorlint
+	}
+	signaturePacket, err := packet.Read(block.Body)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ktclient: %w: %v", errSignedKeyListSignature, err) //nolint:errorlint
+	}
+	parsed, ok := signaturePacket.(*packet.Signature)
+	if !ok {
+		return time.Time{}, fmt.Errorf("ktclient: %w: not a signature", errSignedKeyListSignature)
+	}
+
+	return parsed.CreationTime, nil
+}
@@ -0,0 +1,115 @@
+package ktclient
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestSignedSKLAt returns a signed key list of a key generated and
+// signed at creationTime.
+func newTestSignedSKLAt(t *testing.T, creationTime time.Time) *testSignedSKL {
+	t.Helper()
+	config := &packet.Config{ //nolint:exhaustruct
+		Algorithm: packet.PubKeyAlgoEdDSA,
+		Time:      func() time.Time { return creationTime },
+	}
+	entity, err := openpgp.NewEntity("", "", "alice@proton.me", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(SignedKeyList{
+		testSignedKeyListEntry(t, entity, 1, KeyFlagNotCompromised|KeyFlagNotObsolete),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signature strings.Builder
+	if err := openpgp.ArmoredDetachSign(&signature, entity, strings.NewReader(string(data)), config); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testSignedSKL{
+		entity:    entity,
+		data:      string(data),
+		signature: signature.String(),
+		publicKey: armorTestPublicKey(t, entity),
+	}
+}
+
+func TestCheckPendingInclusion(t *testing.T) {
+	t.Parallel()
+	epoch := &Epoch{EpochID: 10, CertificateTime: 1_700_000_000} //nolint:exhaustruct
+	certificateTime := time.Unix(epoch.CertificateTime, 0)
+	recent := newTestSignedSKLAt(t, certificateTime.Add(-time.Hour)).signature
+	old := newTestSignedSKLAt(t, certificateTime.Add(-25*time.Hour)).signature
+	policy := &GracePeriodPolicy{MaxInclusionDelay: 30 * time.Minute}
+
+	// The list is in the tree of the epoch.
+	pending, err := policy.CheckPendingInclusion(old, 10, epoch, epoch.CertificateTime)
+	assert.NoError(t, err)
+	assert.False(t, pending)
+
+	for _, minEpochID := range []int{0, 11} {
+		pending, err = (*GracePeriodPolicy)(nil).CheckPendingInclusion(recent, minEpochID, epoch, epoch.CertificateTime)
+		assert.NoError(t, err)
+		assert.True(t, pending)
+		_, err = (*GracePeriodPolicy)(nil).CheckPendingInclusion(old, minEpochID, epoch, epoch.CertificateTime)
+		assert.True(t, errors.Is(err, errInclusionDelay), err)
+		_, err = policy.CheckPendingInclusion(recent, minEpochID, epoch, epoch.CertificateTime)
+		assert.True(t, errors.Is(err, errInclusionDelay), err)
+	}
+	// A list created after the epoch.
+	created := newTestSignedSKLAt(t, certificateTime.Add(time.Hour)).signature
+	pending, err = policy.CheckPendingInclusion(created, 0, epoch, certificateTime.Add(70*time.Minute).Unix())
+	assert.NoError(t, err)
+	assert.True(t, pending)
+
+	// A list created in the future, up to the clock skew.
+	_, err = policy.CheckPendingInclusion(created, 0, epoch, epoch.CertificateTime)
+	assert.True(t, errors.Is(err, errInclusionDelay), err)
+	_, err = (*GracePeriodPolicy)(nil).CheckPendingInclusion(created, 0, epoch, certificateTime.Add(55*time.Minute).Unix())
+	assert.NoError(t, err)
+	future := newTestSignedSKLAt(t, certificateTime.Add(24*365*time.Hour)).signature
+	_, err = (*GracePeriodPolicy)(nil).CheckPendingInclusion(future, 0, epoch, epoch.CertificateTime)
+	assert.True(t, errors.Is(err, errInclusionDelay), err)
+
+	// No epoch was published since the list was created.
+	later := certificateTime.Add(26 * time.Hour).Unix()
+	_, err = (*GracePeriodPolicy)(nil).CheckPendingInclusion(created, 0, epoch, later)
+	assert.True(t, errors.Is(err, errInclusionDelay), err)
+
+	_, err = policy.CheckPendingInclusion("not a signature", 0, epoch, epoch.CertificateTime)
+	assert.True(t, errors.Is(err, errSignedKeyListSignature), err)
+}
+
+func TestKeyLookupVerifierGracePeriod(t *testing.T) {
+	t.Parallel()
+	source := newFakeKeyLookupSource(t)
+	source.publishEpoch()
+	certificateTime := time.Unix(source.epochs[1].CertificateTime, 0)
+	clock := NewFakeClock(certificateTime.Add(10 * time.Minute))
+	verifier := newTestKeyLookupVerifierWithOptions(t, source, &KeyLookupOptions{Clock: clock}) //nolint:exhaustruct
+
+	skl := newTestSignedSKLAt(t, certificateTime.Add(-time.Hour))
+	verdict, err := verifier.Verify(testKeyLookup(skl, 1, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, KeyLookupNotYetInKT, verdict)
+
+	// The same epoch stays the latest one for too long.
+	clock.Advance(24 * time.Hour)
+	verdict, err = verifier.Verify(testKeyLookup(skl, 1, 0))
+	assert.True(t, errors.Is(err, errInclusionDelay), err)
+	assert.Equal(t, KeyLookupFailed, verdict)
+
+	skl = newTestSignedSKLAt(t, certificateTime.Add(-48*time.Hour))
+	verdict, err = verifier.Verify(testKeyLookup(skl, 1, 0))
+	assert.True(t, errors.Is(err, errInclusionDelay), err)
+	assert.Equal(t, KeyLookupFailed, verdict)
+}
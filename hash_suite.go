@@ -0,0 +1,97 @@
+package ktclient
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+)
+
+// HashSuite is the set of hashes of a tree model: the hashes of the nodes
+// and leaves of the Merkle tree, and the chain hash of the epochs.
+type HashSuite interface {
+	// Name identifies the suite in transcripts.
+	Name() string
+	// Size is the size of the hashes.
+	Size() int
+	// EmptyNode is the value of the empty subtrees.
+	EmptyNode() []byte
+	// NodeHash hashes the children of a node.
+	NodeHash(left, right []byte) []byte
+	// LeafHash hashes the data of a leaf.
+	LeafHash(data []byte) []byte
+	// ChainHash hashes the previous chain hash of an epoch and its tree hash.
+	ChainHash(previousChainHash, treeHash []byte) []byte
+}
+
+// minHashSuiteSize is the minimum size of the hashes of a HashSuite: the
+// chain hash is split in two labels of at least 16 bytes in epoch names.
+const minHashSuiteSize = 16
+
+// NewHashSuite returns the HashSuite hashing everything with a single hash
+// function: nodes and chain hashes are the hash of the concatenation of
+// their inputs, and empty nodes are all zeros. The verifications reject
+// suites of hashes shorter than 16 bytes.
+func NewHashSuite(name string, newHash func() hash.Hash) HashSuite {
+	return &hashFuncSuite{name: name, newHash: newHash, size: newHash().Size()}
+}
+
+// SHA256HashSuite returns the SHA-256 hash suite of the tree versions 0 and 1.
+func SHA256HashSuite() HashSuite {
+	return sha256HashSuite
+}
+
+var sha256HashSuite = NewHashSuite("SHA-256", sha256.New)
+
+type hashFuncSuite struct {
+	name    string
+	newHash func() hash.Hash
+	size    int
+}
+
+func (s *hashFuncSuite) Name() string {
+	return s.name
+}
+
+func (s *hashFuncSuite) Size() int {
+	return s.size
+}
+
+func (s *hashFuncSuite) EmptyNode() []byte {
+	return make([]byte, s.size)
+}
+
+func (s *hashFuncSuite) NodeHash(left, right []byte) []byte {
+	return s.hash(left, right)
+}
+
+func (s *hashFuncSuite) LeafHash(data []byte) []byte {
+	return s.hash(data)
+}
+
+func (s *hashFuncSuite) ChainHash(previousChainHash, treeHash []byte) []byte {
+	return s.hash(previousChainHash, treeHash)
+}
+
+func (s *hashFuncSuite) hash(inputs ...[]byte) []byte {
+	hashFunc := s.newHash()
+	for _, input := range inputs {
+		hashFunc.Write(input) //nolint:errcheck
+	}
+
+	return hashFunc.Sum(nil)
+}
+
+// resolveHashSuite returns suite, or the hash suite of the resolved tree
+// version if it is nil. It rejects suites of too short hashes.
+func resolveHashSuite(suite HashSuite, treeVersion TreeVersion) (HashSuite, error) {
+	if suite == nil {
+		return treeVersion.hashSuite(), nil
+	}
+	if suite.Size() < minHashSuiteSize {
+		return nil, fmt.Errorf(
+			"ktclient: %w: %s hashes are shorter than %d bytes", errHashSuite, suite.Name(), minHashSuiteSize,
+		)
+	}
+
+	return suite, nil
+}
@@ -0,0 +1,132 @@
+package ktclient
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/fnv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testSHA512_256HashSuite() HashSuite {
+	return NewHashSuite("SHA-512/256", sha512.New512_256)
+}
+
+func TestSHA256HashSuite(t *testing.T) {
+	t.Parallel()
+	for _, treeVersion := range []TreeVersion{TreeVersion0, TreeVersion1} {
+		suite, err := resolveHashSuite(nil, treeVersion)
+		assert.NoError(t, err)
+		assert.Equal(t, SHA256HashSuite(), suite)
+	}
+	suite := testSHA512_256HashSuite()
+	resolved, err := resolveHashSuite(suite, TreeVersion1)
+	assert.NoError(t, err)
+	assert.Equal(t, suite, resolved)
+
+	suite = SHA256HashSuite()
+	assert.Equal(t, "SHA-256", suite.Name())
+	assert.Equal(t, make([]byte, sha256.Size), suite.EmptyNode())
+	nodeHash := sha256.Sum256([]byte("leftright"))
+	assert.Equal(t, nodeHash[:], suite.NodeHash([]byte("left"), []byte("right")))
+	leafHash := sha256.Sum256([]byte("leaf"))
+	assert.Equal(t, leafHash[:], suite.LeafHash([]byte("leaf")))
+}
+
+func TestHashSuiteTreeReplay(t *testing.T) {
+	t.Parallel()
+	suite := testSHA512_256HashSuite()
+	replay, err := NewTreeReplay(&TreeReplayOptions{HashSuite: suite}) //nolint:exhaustruct
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := newReferenceTree(sha512.New512_256)
+	sha256Tree := newReferenceTree(sha256.New)
+	for i, updates := range testEpochUpdates() {
+		applyToReference(t, tree, TreeVersion1, updates)
+		applyToReference(t, sha256Tree, TreeVersion1, updates)
+		epoch := &Epoch{EpochID: 1 + i, TreeHash: hex.EncodeToString(tree.rootHash())} //nolint:exhaustruct
+		assert.NoError(t, replay.Apply(epoch, updates))
+	}
+	assert.NotEqual(t, sha256Tree.rootHash(), replay.RootHash())
+
+	for path, leaf := range tree.leaves {
+		proof := tree.proof([]byte(path))
+		proof.ProofType = presenceProofType
+		rootHash, err := computeRootHash([]byte(path), proof, leaf, suite)
+		assert.NoError(t, err)
+		assert.Equal(t, replay.RootHash(), rootHash)
+	}
+}
+
+func TestHashSuiteInsertionProof(t *testing.T) {
+	t.Parallel()
+	vrfHash, err := verifyVRFOutput(testSKLEmail, testSKLVRFProof, testVRFPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const signedKeyList = "signed key list"
+	tree := newReferenceTree(sha512.New512_256)
+	applyToReference(t, tree, TreeVersion1, []TreeUpdate{
+		{VRFOutput: hex.EncodeToString(vrfHash), Revision: 1, SignedKeyList: signedKeyList, MinEpochID: 5},
+		testTreeUpdate("bob@proton.me", 1, "bob 1"),
+	})
+	proof := tree.proof(TreeVersion1.treePath(vrfHash, 1))
+	proof.ProofType = presenceProofType
+	proof.VRFProofHex = testSKLVRFProof
+	rootHash := hex.EncodeToString(tree.rootHash())
+
+	options := &ProofOptions{HashSuite: testSHA512_256HashSuite()} //nolint:exhaustruct
+	assert.NoError(t, VerifyInsertionProofWithOptions(
+		testSKLEmail, 1, signedKeyList, 5, testVRFPublicKey, rootHash, proof, options,
+	))
+	err = VerifyInsertionProof(testSKLEmail, 1, signedKeyList, 5, testVRFPublicKey, rootHash, proof)
+	assert.True(t, errors.Is(err, errIntegrity), err)
+}
+
+func TestHashSuiteChainHash(t *testing.T) {
+	t.Parallel()
+	previousChainHash := sha512.Sum512_256([]byte("previous"))
+	treeHash := sha512.Sum512_256([]byte("tree"))
+	chainHash := sha512.Sum512_256(append(previousChainHash[:], treeHash[:]...))
+	epoch := &Epoch{ //nolint:exhaustruct
+		PreviousChainHash: hex.EncodeToString(previousChainHash[:]),
+		TreeHash:          hex.EncodeToString(treeHash[:]),
+		ChainHash:         hex.EncodeToString(chainHash[:]),
+	}
+	computed, err := verifyChainHash(epoch, testSHA512_256HashSuite())
+	assert.NoError(t, err)
+	assert.Equal(t, chainHash[:], computed)
+	_, err = verifyChainHash(epoch, SHA256HashSuite())
+	assert.True(t, errors.Is(err, errIntegrity), err)
+
+	options := &EpochOptions{HashSuite: testSHA512_256HashSuite()} //nolint:exhaustruct
+	checkpoint, err := NewEpochCheckpointWithOptions(epoch, "dev.proton.wtf", options)
+	assert.NoError(t, err)
+	assert.Equal(t, epoch.ChainHash, checkpoint.ChainHash)
+	_, err = NewEpochCheckpoint(epoch, "dev.proton.wtf")
+	assert.True(t, errors.Is(err, errIntegrity), err)
+}
+
+func TestShortHashSuite(t *testing.T) {
+	t.Parallel()
+	suite := NewHashSuite("FNV-64", func() hash.Hash { return fnv.New64() })
+	_, err := VerifyEpochWithOptions(newTestEpoch(), "dev.proton.wtf", 1_689_062_740, &EpochOptions{ //nolint:exhaustruct
+		HashSuite: suite,
+	})
+	assert.True(t, errors.Is(err, errHashSuite), err)
+	_, err = NewEpochCheckpointWithOptions(newTestEpoch(), "dev.proton.wtf", &EpochOptions{HashSuite: suite}) //nolint:exhaustruct
+	assert.True(t, errors.Is(err, errHashSuite), err)
+	_, err = NewTreeReplay(&TreeReplayOptions{HashSuite: suite}) //nolint:exhaustruct
+	assert.True(t, errors.Is(err, errHashSuite), err)
+	proof, rootHash := testSKLProof(t, "signed key list", 1, 1)
+	err = VerifyInsertionProofWithOptions(
+		testSKLEmail, 1, "signed key list", 1, testVRFPublicKey, rootHash, proof,
+		&ProofOptions{HashSuite: suite}, //nolint:exhaustruct
+	)
+	assert.True(t, errors.Is(err, errHashSuite), err)
+}
@@ -0,0 +1,69 @@
+package ktclient
+
+import (
+	"encoding/base64"
+
+	"github.com/google/certificate-transparency-go/x509util"
+	"github.com/pkg/errors"
+)
+
+// CertificateInfo describes an epoch certificate, for inspection.
+type CertificateInfo struct {
+	CommonName   string
+	DNSNames     []string
+	Issuer       string
+	SerialNumber string
+	NotBefore    int64
+	NotAfter     int64
+	SCTs         []SCTInfo
+	// Claim is the epoch claimed by the certificate, nil if ClaimError is set.
+	Claim      *EpochClaim `json:",omitempty"`
+	ClaimError string      `json:",omitempty"`
+}
+
+// SCTInfo describes an SCT embedded in a certificate.
+// Operator is empty if the log is not in the CT log list.
+type SCTInfo struct {
+	LogID     string
+	Operator  string
+	Timestamp uint64
+}
+
+// InspectCertificate describes the first certificate of the PEM encoded
+// chain. The certificate is not verified.
+func InspectCertificate(certificateChain string) (*CertificateInfo, error) {
+	cert, _, err := convertPEMEncodedCertToX509Cert([]byte(certificateChain))
+	if err != nil {
+		return nil, err
+	}
+	publicKeys, err := parseCTPublicKeys(ctLogs)
+	if err != nil {
+		return nil, err
+	}
+	scts, err := x509util.ParseSCTsFromSCTList(&cert.SCTList)
+	if err != nil {
+		return nil, errors.Wrap(err, "ktclient: parse SCTs")
+	}
+	info := &CertificateInfo{ //nolint:exhaustruct
+		CommonName:   cert.Subject.CommonName,
+		DNSNames:     cert.DNSNames,
+		Issuer:       cert.Issuer.CommonName,
+		SerialNumber: cert.SerialNumber.Text(16),
+		NotBefore:    cert.NotBefore.Unix(),
+		NotAfter:     cert.NotAfter.Unix(),
+		SCTs:         make([]SCTInfo, 0, len(scts)),
+	}
+	for _, sct := range scts {
+		logID := base64.StdEncoding.EncodeToString(sct.LogID.KeyID[:])
+		info.SCTs = append(info.SCTs, SCTInfo{
+			LogID:     logID,
+			Operator:  publicKeys[logID].OperatorName,
+			Timestamp: sct.Timestamp,
+		})
+	}
+	if info.Claim, err = epochClaimFromX509(cert); err != nil {
+		info.ClaimError = err.Error()
+	}
+
+	return info, nil
+}
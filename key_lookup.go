@@ -0,0 +1,379 @@
+package ktclient
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// defaultMaxRevisions is the number of revisions a KeyLookupVerifier checks
+// at most by default for an address served without signed key list.
+const defaultMaxRevisions = 100
+
+// KeyLookupVerdict is the outcome of the verification of the keys of an address.
+type KeyLookupVerdict int
+
+const (
+	// KeyLookupFailed means the keys could not be verified: they must not be trusted.
+	// It is the zero value.
+	KeyLookupFailed KeyLookupVerdict = iota
+	// KeyLookupVerified means the signed key list is in key transparency and the keys match it.
+	KeyLookupVerified
+	// KeyLookupAbsent means the address has no keys in key transparency.
+	KeyLookupAbsent
+	// KeyLookupObsolete means the signed key list was marked obsolete in key transparency.
+	KeyLookupObsolete
+	// KeyLookupNotYetInKT means the signed key list is valid, but pending
+	// inclusion in key transparency, see GracePeriodPolicy.
+	KeyLookupNotYetInKT
+)
+
+// String implements fmt.Stringer.
+func (v KeyLookupVerdict) String() string {
+	switch v {
+	case KeyLookupFailed:
+		return "failed"
+	case KeyLookupVerified:
+		return "verified"
+	case KeyLookupAbsent:
+		return "absent"
+	case KeyLookupObsolete:
+		return "obsolete"
+	case KeyLookupNotYetInKT:
+		return "not yet in KT"
+	default:
+		return fmt.Sprintf("KeyLookupVerdict(%d)", int(v))
+	}
+}
+
+// KeyLookupSource fetches the epochs and insertion proofs of a key
+// transparency deployment. Applications implement it on top of their API client.
+type KeyLookupSource interface {
+	EpochSource
+	// GetProof returns the insertion proof of the revision of the signed key
+	// list of the email, in the tree of the epoch.
+	GetProof(email string, epochID int, revision int) (*KeyLookupProof, error)
+}
+
+// KeyLookupProof is an insertion proof, as served by the API.
+type KeyLookupProof struct {
+	Proof *InsertionProof
+	// ObsolescenceToken and MinEpochID are the data of the leaf
+	// of obsolescence proofs.
+	ObsolescenceToken string
+	MinEpochID        int
+}
+
+// PublishedSignedKeyList is a signed key list as served with the keys of an address.
+type PublishedSignedKeyList struct {
+	Data      string
+	Signature string
+	Revision  int
+	// MinEpochID is the first epoch including the signed key list,
+	// 0 if it is not in key transparency yet.
+	MinEpochID int
+}
+
+// KeyLookup is the result of a key lookup, to verify.
+type KeyLookup struct {
+	Email string
+	Keys  []AddressKey
+	// SignedKeyList is nil if the address has none.
+	SignedKeyList *PublishedSignedKeyList
+}
+
+// KeyLookupOptions configures a KeyLookupVerifier.
+type KeyLookupOptions struct {
+	// EpochOptions tunes the verification of the epochs.
+	EpochOptions *EpochOptions
+	// SigningKey, if not nil, verifies the epochs as signed epochs,
+	// see VerifySignedEpoch.
+	SigningKey *EpochSigningKey
+	// GracePeriod tells how long signed key lists may be pending inclusion.
+	GracePeriod *GracePeriodPolicy
+	// Clock tells the time the epochs and signatures are verified at,
+	// the system clock if nil.
+	Clock Clock
+	// MaxRevisions bounds the revisions checked for an address served
+	// without signed key list, whose proofs are all fetched. It defaults to 100.
+	MaxRevisions int
+}
+
+// keyLookupCacheKey identifies a verified insertion proof.
+type keyLookupCacheKey struct {
+	email    string
+	revision int
+	epochID  int
+}
+
+// keyLookupCacheEntry is a verified insertion proof, and the signed key list
+// it was verified for.
+type keyLookupCacheEntry struct {
+	proofType int
+	dataHash  [sha256.Size]byte
+}
+
+// KeyLookupVerifier verifies the keys of addresses against key transparency:
+// the signature of their signed key list, the keys against the list, the
+// latest epoch and the insertion proof of the list in its tree.
+// Verified proofs are cached per email, revision and epoch, so that looking
+// up the same keys again only fetches the latest epoch ID. Cached epochs are
+// still verified again at the time of each lookup.
+type KeyLookupVerifier struct {
+	mutex      sync.Mutex
+	source     KeyLookupSource
+	baseDomain string
+	vrfKeySet  *VRFKeySet
+	options    KeyLookupOptions
+	epochs     map[int]*Epoch
+	proofs     map[keyLookupCacheKey]*keyLookupCacheEntry
+}
+
+// NewKeyLookupVerifier creates a KeyLookupVerifier of the epochs and proofs
+// of the source, verifying the VRF proofs with the keys of vrfKeySet.
+func NewKeyLookupVerifier(
+	source KeyLookupSource,
+	baseDomain string,
+	vrfKeySet *VRFKeySet,
+	options *KeyLookupOptions,
+) *KeyLookupVerifier {
+	verifier := &KeyLookupVerifier{ //nolint:exhaustruct
+		source:     source,
+		baseDomain: baseDomain,
+		vrfKeySet:  vrfKeySet,
+		epochs:     make(map[int]*Epoch),
+		proofs:     make(map[keyLookupCacheKey]*keyLookupCacheEntry),
+	}
+	if options != nil {
+		verifier.options = *options
+	}
+	if verifier.options.Clock == nil {
+		verifier.options.Clock = SystemClock()
+	}
+	if verifier.options.MaxRevisions <= 0 {
+		verifier.options.MaxRevisions = defaultMaxRevisions
+	}
+
+	return verifier
+}
+
+// Verify verifies the keys of the lookup. The error tells why the verdict
+// is KeyLookupFailed, including failures to fetch from the source.
+func (v *KeyLookupVerifier) Verify(lookup *KeyLookup) (KeyLookupVerdict, error) {
+	if lookup.SignedKeyList == nil {
+		return v.verifyAbsence(lookup)
+	}
+	skl := lookup.SignedKeyList
+	list, err := ParseSignedKeyList(skl.Data)
+	if err != nil {
+		return KeyLookupFailed, err
+	}
+	if err := list.MatchKeys(lookup.Keys); err != nil {
+		return KeyLookupFailed, err
+	}
+	now := v.options.Clock.Now().Unix()
+	if err := VerifySignedKeyListSignature(skl.Data, skl.Signature, primaryAddressKey(lookup.Keys), now); err != nil {
+		return KeyLookupFailed, err
+	}
+	epoch, err := v.latestEpoch()
+	if err != nil {
+		return KeyLookupFailed, err
+	}
+	pending, err := v.options.GracePeriod.CheckPendingInclusion(skl.Signature, skl.MinEpochID, epoch, now)
+	if err != nil {
+		return KeyLookupFailed, err
+	}
+	if pending {
+		return KeyLookupNotYetInKT, nil
+	}
+	proofType, err := v.verifyProof(lookup.Email, skl.Revision, skl.Data, skl.MinEpochID, epoch)
+	if err != nil {
+		return KeyLookupFailed, err
+	}
+	switch proofType {
+	case presenceProofType:
+		return KeyLookupVerified, nil
+	case obsolescenceProofType:
+		return KeyLookupObsolete, nil
+	default:
+		return KeyLookupFailed, fmt.Errorf("ktclient: %w: signed key list is absent", errKeyLookup)
+	}
+}
+
+// verifyAbsence verifies that an address served without signed key list
+// has none in key transparency, or only obsolete ones.
+func (v *KeyLookupVerifier) verifyAbsence(lookup *KeyLookup) (KeyLookupVerdict, error) {
+	if len(lookup.Keys) != 0 {
+		return KeyLookupFailed, fmt.Errorf("ktclient: %w: keys without signed key list", errKeyLookup)
+	}
+	epoch, err := v.latestEpoch()
+	if err != nil {
+		return KeyLookupFailed, err
+	}
+	// Revisions start at 1 and follow each other: the latest revision is
+	// obsolete if all the revisions up to the first absent one are.
+	for revision := 1; revision <= v.options.MaxRevisions; revision++ {
+		proofType, err := v.verifyProof(lookup.Email, revision, "", 0, epoch)
+		if err != nil {
+			return KeyLookupFailed, err
+		}
+		if proofType == absenceProofType {
+			if revision == 1 {
+				return KeyLookupAbsent, nil
+			}
+
+			return KeyLookupObsolete, nil
+		}
+		if proofType != obsolescenceProofType {
+			return KeyLookupFailed, fmt.Errorf(
+				"ktclient: %w: revision %d of the signed key list is present", errKeyLookup, revision,
+			)
+		}
+	}
+
+	return KeyLookupFailed, fmt.Errorf(
+		"ktclient: %w: more than %d revisions of the signed key list", errKeyLookup, v.options.MaxRevisions,
+	)
+}
+
+// latestEpoch returns the latest epoch, verified. Cached epochs are not
+// fetched again, but verified again at the current time.
+func (v *KeyLookupVerifier) latestEpoch() (*Epoch, error) {
+	epochID, err := v.source.LatestEpochID()
+	if err != nil {
+		return nil, errors.Wrap(err, "ktclient: get latest epoch ID")
+	}
+	v.mutex.Lock()
+	epoch, ok := v.epochs[epochID]
+	v.mutex.Unlock()
+	if ok {
+		if err := v.verifyEpoch(epoch, v.cachedEpochOptions()); err != nil {
+			return nil, err
+		}
+
+		return epoch, nil
+	}
+
+	epoch, err = v.source.GetEpoch(epochID)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("ktclient: get epoch %d", epochID))
+	}
+	if epoch == nil || epoch.EpochID != epochID {
+		return nil, fmt.Errorf("ktclient: %w: epoch %d is missing", errKeyLookup, epochID)
+	}
+	if err := v.verifyEpoch(epoch, v.options.EpochOptions); err != nil {
+		return nil, err
+	}
+	v.mutex.Lock()
+	v.epochs[epochID] = epoch
+	v.mutex.Unlock()
+
+	return epoch, nil
+}
+
+// verifyEpoch verifies the epoch at the current time.
+func (v *KeyLookupVerifier) verifyEpoch(epoch *Epoch, options *EpochOptions) error {
+	now := v.options.Clock.Now().Unix()
+	var err error
+	if v.options.SigningKey != nil {
+		_, err = VerifySignedEpoch(epoch, v.baseDomain, now, v.options.SigningKey, options)
+	} else {
+		_, err = VerifyEpochWithOptions(epoch, v.baseDomain, now, options)
+	}
+
+	return errors.Wrap(err, fmt.Sprintf("ktclient: epoch %d", epoch.EpochID))
+}
+
+// cachedEpochOptions returns the options to verify cached epochs again with:
+// their inclusion in the CT logs, which does not depend on the time,
+// is not checked again.
+func (v *KeyLookupVerifier) cachedEpochOptions() *EpochOptions {
+	if v.options.EpochOptions == nil {
+		return nil
+	}
+	options := *v.options.EpochOptions
+	options.CTLogs = nil
+	options.CTLogPublicKeys = nil
+	options.STHStore = nil
+
+	return &options
+}
+
+// verifyProof fetches and verifies the insertion proof of the revision in
+// the tree of the epoch, returning its type. Verified proofs are cached,
+// with the signed key list they were verified for.
+func (v *KeyLookupVerifier) verifyProof(
+	email string,
+	revision int,
+	signedKeyList string,
+	minEpochID int,
+	epoch *Epoch,
+) (int, error) {
+	key := keyLookupCacheKey{email: email, revision: revision, epochID: epoch.EpochID}
+	dataHash := sha256.Sum256([]byte(signedKeyList))
+	v.mutex.Lock()
+	entry, ok := v.proofs[key]
+	v.mutex.Unlock()
+	if ok {
+		if entry.dataHash != dataHash {
+			return 0, fmt.Errorf(
+				"ktclient: %w: revision %d of %s changed in epoch %d",
+				errKeyLookup, revision, email, epoch.EpochID,
+			)
+		}
+
+		return entry.proofType, nil
+	}
+
+	lookupProof, err := v.source.GetProof(email, epoch.EpochID, revision)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("ktclient: get proof of %s", email))
+	}
+	if lookupProof == nil || lookupProof.Proof == nil {
+		return 0, fmt.Errorf("ktclient: %w: no proof of %s", errKeyLookup, email)
+	}
+	proof := lookupProof.Proof
+	leafData := signedKeyList
+	if proof.ProofType == obsolescenceProofType {
+		// The leaf of an obsolete revision holds the obsolescence token.
+		leafData, minEpochID = lookupProof.ObsolescenceToken, lookupProof.MinEpochID
+	}
+	err = VerifyInsertionProofWithKeySet(
+		email, revision, leafData, minEpochID, v.vrfKeySet, epoch.EpochID, epoch.TreeHash, proof, v.proofOptions(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	v.mutex.Lock()
+	v.proofs[key] = &keyLookupCacheEntry{proofType: proof.ProofType, dataHash: dataHash}
+	v.mutex.Unlock()
+
+	return proof.ProofType, nil
+}
+
+// proofOptions returns the options to verify proofs with the tree version
+// and hash suite of the epochs.
+func (v *KeyLookupVerifier) proofOptions() *ProofOptions {
+	if v.options.EpochOptions == nil {
+		return nil
+	}
+
+	return &ProofOptions{ //nolint:exhaustruct
+		TreeVersion: v.options.EpochOptions.TreeVersion,
+		HashSuite:   v.options.EpochOptions.HashSuite,
+	}
+}
+
+// primaryAddressKey returns the armored primary key among the keys, or
+// an empty string if there is none.
+func primaryAddressKey(keys []AddressKey) string {
+	for _, key := range keys {
+		if key.Primary {
+			return key.PublicKey
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,321 @@
+package ktclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testObsoleteEmail    = "disabledtest@disabled.2.willis.protonhub.org"
+	testObsoleteVRFProof = "80619aac087ff2e9209c265bff1d82dbbf8f8634fcafb1b82bca6e81a45b56c14cdbfbac17cc33868281e84636f515f0125ca118e49228d842d3a757764c495e15cee89704b26a82fa9165ee9ccf8300" //nolint:lll
+	testObsoleteToken    = "0000000064ad241e27f7fe3fb1542f23ebad09847beab8f526fb854a"
+)
+
+var testVRFProofs = map[string]string{
+	testSKLEmail:      testSKLVRFProof,
+	testObsoleteEmail: testObsoleteVRFProof,
+}
+
+// testLeaf is a leaf of the tree of a fakeKeyLookupSource epoch.
+type testLeaf struct {
+	email      string
+	revision   int
+	data       string
+	minEpochID int
+	obsolete   bool
+}
+
+// fakeKeyLookupSource serves signed epochs and the proofs of their trees.
+type fakeKeyLookupSource struct {
+	*fakeEpochSource
+	t             *testing.T
+	signer        *testEpochSigner
+	leaves        map[int][]testLeaf
+	proofsFetched int
+}
+
+func newFakeKeyLookupSource(t *testing.T) *fakeKeyLookupSource {
+	t.Helper()
+
+	return &fakeKeyLookupSource{
+		fakeEpochSource: newFakeEpochSource(nil),
+		t:               t,
+		signer:          newTestEpochSigner(t),
+		leaves:          make(map[int][]testLeaf),
+	}
+}
+
+func testVRFOutput(t *testing.T, email string) []byte {
+	t.Helper()
+	vrfHash, err := verifyVRFOutput(email, testVRFProofs[email], testVRFPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return vrfHash
+}
+
+func (s *fakeKeyLookupSource) tree(epochID int) *referenceTree {
+	tree := newReferenceTree(sha256.New)
+	for _, leaf := range s.leaves[epochID] {
+		applyToReference(s.t, tree, TreeVersion1, []TreeUpdate{{
+			VRFOutput:     hex.EncodeToString(testVRFOutput(s.t, leaf.email)),
+			Revision:      leaf.revision,
+			SignedKeyList: leaf.data,
+			MinEpochID:    leaf.minEpochID,
+		}})
+	}
+
+	return tree
+}
+
+// publishEpoch publishes the next epoch, with a tree of the leaves.
+func (s *fakeKeyLookupSource) publishEpoch(leaves ...testLeaf) {
+	var previous *Epoch
+	if s.latest > 0 {
+		previous = s.epochs[s.latest]
+	}
+	epoch := s.signer.nextEpoch(previous)
+	s.leaves[epoch.EpochID] = leaves
+	epoch.TreeHash = hex.EncodeToString(s.tree(epoch.EpochID).rootHash())
+	s.signer.sign(epoch)
+	s.publish(epoch)
+}
+
+func (s *fakeKeyLookupSource) GetProof(email string, epochID int, revision int) (*KeyLookupProof, error) {
+	s.proofsFetched++
+	path := TreeVersion1.treePath(testVRFOutput(s.t, email), revision)
+	proof := s.tree(epochID).proof(path)
+	proof.VRFProofHex = testVRFProofs[email]
+	proof.ProofType = absenceProofType
+	lookupProof := &KeyLookupProof{Proof: proof} //nolint:exhaustruct
+	for _, leaf := range s.leaves[epochID] {
+		if leaf.email == email && leaf.revision == revision {
+			proof.ProofType = presenceProofType
+			if leaf.obsolete {
+				proof.ProofType = obsolescenceProofType
+				lookupProof.ObsolescenceToken, lookupProof.MinEpochID = leaf.data, leaf.minEpochID
+			}
+		}
+	}
+
+	return lookupProof, nil
+}
+
+func newTestKeyLookupVerifier(t *testing.T, source *fakeKeyLookupSource) *KeyLookupVerifier {
+	t.Helper()
+
+	// The keys of the signed key lists are generated at the current time.
+	return newTestKeyLookupVerifierWithOptions(t, source, &KeyLookupOptions{ //nolint:exhaustruct
+		Clock: NewFakeClock(time.Now().Add(time.Minute)),
+	})
+}
+
+// newTestKeyLookupVerifierWithOptions returns a verifier of the signed epochs
+// of the source, with the options.
+func newTestKeyLookupVerifierWithOptions(
+	t *testing.T,
+	source *fakeKeyLookupSource,
+	options *KeyLookupOptions,
+) *KeyLookupVerifier {
+	t.Helper()
+	keySet := NewVRFKeySet()
+	if err := keySet.AddKey(testVRFPublicKey, 1); err != nil {
+		t.Fatal(err)
+	}
+	options.SigningKey = source.signer.signingKey
+
+	return NewKeyLookupVerifier(source, testAuditBaseDomain, keySet, options)
+}
+
+func testKeyLookup(skl *testSignedSKL, revision, minEpochID int) *KeyLookup {
+	return &KeyLookup{
+		Email: testSKLEmail,
+		Keys:  []AddressKey{{PublicKey: skl.publicKey, Primary: true, Flags: 3}},
+		SignedKeyList: &PublishedSignedKeyList{
+			Data:       skl.data,
+			Signature:  skl.signature,
+			Revision:   revision,
+			MinEpochID: minEpochID,
+		},
+	}
+}
+
+func TestKeyLookupVerifier(t *testing.T) {
+	t.Parallel()
+	skl := newTestSignedSKL(t)
+	source := newFakeKeyLookupSource(t)
+	source.publishEpoch(testLeaf{email: testSKLEmail, revision: 1, data: skl.data, minEpochID: 1}) //nolint:exhaustruct
+	verifier := newTestKeyLookupVerifier(t, source)
+
+	verdict, err := verifier.Verify(testKeyLookup(skl, 1, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, KeyLookupVerified, verdict)
+	// The verified proof is cached.
+	verdict, err = verifier.Verify(testKeyLookup(skl, 1, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, KeyLookupVerified, verdict)
+	assert.Equal(t, 1, source.proofsFetched)
+
+	// Other data for the same revision, even correctly signed.
+	changed := *skl
+	changed.data = " " + skl.data
+	changed.signature = signTestSKL(t, skl.entity, changed.data)
+	verdict, err = verifier.Verify(testKeyLookup(&changed, 1, 1))
+	assert.True(t, errors.Is(err, errKeyLookup), err)
+	assert.Equal(t, KeyLookupFailed, verdict)
+
+	// A revision absent from the tree.
+	verdict, err = verifier.Verify(testKeyLookup(skl, 2, 1))
+	assert.True(t, errors.Is(err, errKeyLookup), err)
+	assert.Equal(t, KeyLookupFailed, verdict)
+
+	// The keys are served without their signed key list.
+	verdict, err = verifier.Verify(&KeyLookup{Email: testSKLEmail}) //nolint:exhaustruct
+	assert.True(t, errors.Is(err, errKeyLookup), err)
+	assert.Equal(t, KeyLookupFailed, verdict)
+
+	// The keys do not match the list.
+	lookup := testKeyLookup(skl, 1, 1)
+	lookup.Keys[0].Flags = 1
+	verdict, err = verifier.Verify(lookup)
+	assert.True(t, errors.Is(err, errKeyMismatch), err)
+	assert.Equal(t, KeyLookupFailed, verdict)
+
+	// The list is not signed by the primary key.
+	lookup = testKeyLookup(skl, 1, 1)
+	lookup.SignedKeyList.Signature = newTestSignedSKL(t).signature
+	verdict, err = verifier.Verify(lookup)
+	assert.True(t, errors.Is(err, errSignedKeyListSignature), err)
+	assert.Equal(t, KeyLookupFailed, verdict)
+}
+
+func TestKeyLookupVerifierNotYetInKT(t *testing.T) {
+	t.Parallel()
+	skl := newTestSignedSKL(t)
+	source := newFakeKeyLookupSource(t)
+	source.publishEpoch()
+	verifier := newTestKeyLookupVerifier(t, source)
+
+	verdict, err := verifier.Verify(testKeyLookup(skl, 1, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, KeyLookupNotYetInKT, verdict)
+	verdict, err = verifier.Verify(testKeyLookup(skl, 1, 2))
+	assert.NoError(t, err)
+	assert.Equal(t, KeyLookupNotYetInKT, verdict)
+	assert.Equal(t, 0, source.proofsFetched)
+
+	source.publishEpoch(testLeaf{email: testSKLEmail, revision: 1, data: skl.data, minEpochID: 2}) //nolint:exhaustruct
+	verdict, err = verifier.Verify(testKeyLookup(skl, 1, 2))
+	assert.NoError(t, err)
+	assert.Equal(t, KeyLookupVerified, verdict)
+}
+
+func TestKeyLookupVerifierAbsentAndObsolete(t *testing.T) {
+	t.Parallel()
+	skl := newTestSignedSKL(t)
+	source := newFakeKeyLookupSource(t)
+	source.publishEpoch(testLeaf{email: testSKLEmail, revision: 1, data: skl.data, minEpochID: 1}) //nolint:exhaustruct
+	verifier := newTestKeyLookupVerifier(t, source)
+
+	verdict, err := verifier.Verify(&KeyLookup{Email: testObsoleteEmail}) //nolint:exhaustruct
+	assert.NoError(t, err)
+	assert.Equal(t, KeyLookupAbsent, verdict)
+
+	source.publishEpoch(
+		testLeaf{email: testSKLEmail, revision: 1, data: testObsoleteToken, minEpochID: 2, obsolete: true},
+		testLeaf{email: testObsoleteEmail, revision: 1, data: testObsoleteToken, minEpochID: 2, obsolete: true},
+	)
+	verdict, err = verifier.Verify(&KeyLookup{Email: testObsoleteEmail}) //nolint:exhaustruct
+	assert.NoError(t, err)
+	assert.Equal(t, KeyLookupObsolete, verdict)
+	verdict, err = verifier.Verify(testKeyLookup(skl, 1, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, KeyLookupObsolete, verdict)
+
+	// An epoch which does not verify.
+	epoch := source.signer.nextEpoch(source.epochs[source.latest])
+	epoch.TreeHash = hex.EncodeToString(make([]byte, sha256.Size))
+	source.publish(epoch)
+	verdict, err = verifier.Verify(testKeyLookup(skl, 1, 1))
+	assert.True(t, errors.Is(err, errIntegrity), err)
+	assert.Equal(t, KeyLookupFailed, verdict)
+}
+
+func TestKeyLookupVerifierLaterRevisions(t *testing.T) {
+	t.Parallel()
+	source := newFakeKeyLookupSource(t)
+	source.publishEpoch(
+		testLeaf{email: testObsoleteEmail, revision: 1, data: testObsoleteToken, minEpochID: 1, obsolete: true},
+		testLeaf{email: testObsoleteEmail, revision: 2, data: testObsoleteToken, minEpochID: 1, obsolete: true},
+	)
+	verifier := newTestKeyLookupVerifier(t, source)
+	verdict, err := verifier.Verify(&KeyLookup{Email: testObsoleteEmail}) //nolint:exhaustruct
+	assert.NoError(t, err)
+	assert.Equal(t, KeyLookupObsolete, verdict)
+	assert.Equal(t, 3, source.proofsFetched)
+
+	// The keys are withheld while a later revision is present.
+	source.publishEpoch(
+		testLeaf{email: testObsoleteEmail, revision: 1, data: testObsoleteToken, minEpochID: 1, obsolete: true},
+		testLeaf{email: testObsoleteEmail, revision: 2, data: "signed key list", minEpochID: 2}, //nolint:exhaustruct
+	)
+	verdict, err = verifier.Verify(&KeyLookup{Email: testObsoleteEmail}) //nolint:exhaustruct
+	assert.Error(t, err)
+	assert.Equal(t, KeyLookupFailed, verdict)
+}
+
+func TestKeyLookupVerifierMaxRevisions(t *testing.T) {
+	t.Parallel()
+	// The source never serves an absence proof for the address.
+	var leaves []testLeaf
+	for revision := 1; revision <= 10; revision++ {
+		leaves = append(leaves, testLeaf{
+			email: testObsoleteEmail, revision: revision, data: testObsoleteToken, minEpochID: 1, obsolete: true,
+		})
+	}
+	source := newFakeKeyLookupSource(t)
+	source.publishEpoch(leaves...)
+	verifier := newTestKeyLookupVerifierWithOptions(t, source, &KeyLookupOptions{ //nolint:exhaustruct
+		Clock:        NewFakeClock(time.Now()),
+		MaxRevisions: 3,
+	})
+	verdict, err := verifier.Verify(&KeyLookup{Email: testObsoleteEmail}) //nolint:exhaustruct
+	assert.True(t, errors.Is(err, errKeyLookup), err)
+	assert.Equal(t, KeyLookupFailed, verdict)
+	assert.Equal(t, 3, source.proofsFetched)
+}
+
+func TestKeyLookupVerifierChecksCachedEpochFreshness(t *testing.T) {
+	t.Parallel()
+	source := newFakeKeyLookupSource(t)
+	source.publishEpoch()
+	clock := NewFakeClock(time.Unix(source.epochs[1].CertificateTime, 0).Add(10 * time.Minute))
+	verifier := newTestKeyLookupVerifierWithOptions(t, source, &KeyLookupOptions{ //nolint:exhaustruct
+		EpochOptions: &EpochOptions{Freshness: &FreshnessPolicy{MaxEpochAge: time.Hour}}, //nolint:exhaustruct
+		Clock:        clock,
+	})
+	verdict, err := verifier.Verify(&KeyLookup{Email: testObsoleteEmail}) //nolint:exhaustruct
+	assert.NoError(t, err)
+	assert.Equal(t, KeyLookupAbsent, verdict)
+
+	// The same epoch stays the latest one, and becomes stale.
+	fetched := source.fetched
+	clock.Advance(2 * time.Hour)
+	verdict, err = verifier.Verify(&KeyLookup{Email: testObsoleteEmail}) //nolint:exhaustruct
+	assert.True(t, errors.Is(err, ErrStaleEpoch), err)
+	assert.Equal(t, KeyLookupFailed, verdict)
+	assert.Equal(t, fetched, source.fetched)
+}
+
+func TestKeyLookupVerdictString(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "verified", KeyLookupVerified.String())
+	assert.Equal(t, "not yet in KT", KeyLookupNotYetInKT.String())
+	assert.Equal(t, "KeyLookupVerdict(9)", KeyLookupVerdict(9).String())
+}
@@ -0,0 +1,77 @@
+package ktclient
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+// AlternateNameError reports the name of an epoch certificate
+// that violates the strict alternate name policy.
+type AlternateNameError struct {
+	Name   string
+	Reason string
+}
+
+func (e *AlternateNameError) Error() string {
+	return fmt.Sprintf("ktclient: %v: alt. name %q: %s", errCert, e.Name, e.Reason)
+}
+
+func (e *AlternateNameError) Unwrap() error {
+	return errCert
+}
+
+// epochSubjectName returns the name an epoch certificate has alongside
+// the epoch name: epoch.<epochID>.<version>.<baseDomain>.
+func epochSubjectName(epochID int, nameVersion int, baseDomain string) string {
+	return fmt.Sprintf("epoch.%d.%d.%s", epochID, nameVersion, baseDomain)
+}
+
+// verifyStrictAlternateNames checks that the DNS names of the certificate
+// are exactly the epoch name and the epoch subject name, that it has no
+// other kind of alternate name, and that its common name is one of them.
+func verifyStrictAlternateNames(cert *x509.Certificate, epoch *attestedEpoch) error {
+	expected := []string{
+		epoch.name(),
+		epochSubjectName(epoch.epoch.EpochID, epoch.nameVersion, epoch.baseDomain),
+	}
+	switch {
+	case len(cert.EmailAddresses) > 0:
+		return &AlternateNameError{Name: cert.EmailAddresses[0], Reason: "email address not allowed"}
+	case len(cert.IPAddresses) > 0:
+		return &AlternateNameError{Name: cert.IPAddresses[0].String(), Reason: "IP address not allowed"}
+	case len(cert.URIs) > 0:
+		return &AlternateNameError{Name: cert.URIs[0].String(), Reason: "URI not allowed"}
+	}
+	found := make(map[string]bool, len(expected))
+	for _, name := range cert.DNSNames {
+		switch {
+		case strings.Contains(name, "*"):
+			return &AlternateNameError{Name: name, Reason: "wildcard not allowed"}
+		case !containsName(expected, name):
+			return &AlternateNameError{Name: name, Reason: "unrelated to the epoch"}
+		}
+		found[name] = true
+	}
+	for _, name := range expected {
+		if !found[name] {
+			return &AlternateNameError{Name: name, Reason: "missing"}
+		}
+	}
+	if commonName := cert.Subject.CommonName; commonName != "" && !containsName(expected, commonName) {
+		return &AlternateNameError{Name: commonName, Reason: "common name not among the alt. names"}
+	}
+
+	return nil
+}
+
+func containsName(names []string, name string) bool {
+	for _, candidate := range names {
+		if candidate == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,134 @@
+package ktclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	stdx509 "crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestNamedCertificate returns a certificate with the given common name and
+// alternate names, modified by the template function if not nil.
+func newTestNamedCertificate(
+	t *testing.T,
+	commonName string,
+	dnsNames []string,
+	modify func(*stdx509.Certificate),
+) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &stdx509.Certificate{ //nolint:exhaustruct
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName}, //nolint:exhaustruct
+		DNSNames:     dnsNames,
+	}
+	if modify != nil {
+		modify(template)
+	}
+	der, err := stdx509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _, err := convertPEMEncodedCertToX509Cert(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func newTestAttestedEpoch(t *testing.T) *attestedEpoch {
+	t.Helper()
+	epoch := newTestEpoch()
+	chainHash, err := hex.DecodeString(epoch.ChainHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &attestedEpoch{epoch: epoch, chainHash: chainHash, nameVersion: 1, baseDomain: "dev.proton.wtf"}
+}
+
+func TestStrictAlternateNames(t *testing.T) {
+	t.Parallel()
+	epoch := newTestAttestedEpoch(t)
+	validNames := []string{testEpochSubjectName, testEpochName}
+
+	cert, _, err := convertPEMEncodedCertToX509Cert([]byte(certificateChain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, verifyStrictAlternateNames(cert, epoch))
+	assert.NoError(t, verifyStrictAlternateNames(newTestNamedCertificate(t, "", validNames, nil), epoch))
+
+	for name, testCase := range map[string]struct {
+		commonName string
+		dnsNames   []string
+		modify     func(*stdx509.Certificate)
+		violating  string
+	}{
+		"unrelated name": {
+			testEpochSubjectName, append(validNames, testUnrelatedCertName), nil, testUnrelatedCertName,
+		},
+		"wildcard": {
+			testEpochSubjectName, append(validNames, "*.dev.proton.wtf"), nil, "*.dev.proton.wtf",
+		},
+		"other epoch": {
+			testEpochSubjectName, append(validNames, testNextEpochName), nil, testNextEpochName,
+		},
+		"missing subject name": {
+			testEpochName, []string{testEpochName}, nil, testEpochSubjectName,
+		},
+		"missing epoch name": {
+			testEpochSubjectName, []string{testEpochSubjectName}, nil, testEpochName,
+		},
+		"common name": {
+			testUnrelatedCertName, validNames, nil, testUnrelatedCertName,
+		},
+		"IP address": {
+			testEpochSubjectName, validNames,
+			func(template *stdx509.Certificate) { template.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1)} },
+			"127.0.0.1",
+		},
+		"email address": {
+			testEpochSubjectName, validNames,
+			func(template *stdx509.Certificate) { template.EmailAddresses = []string{"alice@proton.me"} },
+			"alice@proton.me",
+		},
+		"URI": {
+			testEpochSubjectName, validNames,
+			func(template *stdx509.Certificate) {
+				template.URIs = []*url.URL{{Scheme: "https", Host: "proton.me"}} //nolint:exhaustruct
+			},
+			"https://proton.me",
+		},
+	} {
+		cert := newTestNamedCertificate(t, testCase.commonName, testCase.dnsNames, testCase.modify)
+		err := verifyStrictAlternateNames(cert, epoch)
+		var nameErr *AlternateNameError
+		if assert.True(t, errors.As(err, &nameErr), name) {
+			assert.Equal(t, testCase.violating, nameErr.Name, name)
+			assert.True(t, errors.Is(err, errCert), name)
+		}
+	}
+}
+
+func TestVerifyEpochStrictAlternateNames(t *testing.T) {
+	t.Parallel()
+	options := &EpochOptions{StrictAlternateNames: true} //nolint:exhaustruct
+	_, err := VerifyEpochWithOptions(newTestEpoch(), "dev.proton.wtf", 1_689_062_740, options)
+	assert.NoError(t, err)
+}
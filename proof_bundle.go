@@ -0,0 +1,322 @@
+package ktclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// proofBundleVersion is the version of the bundles this package produces.
+// Version 1 bundles hold proofs and epochs of TreeVersion1.
+const proofBundleVersion = 1
+
+// proofBundleMagic starts the binary encoding of proof bundles.
+const proofBundleMagic = "KTPB"
+
+// ProofBundle holds everything needed to check one key lookup offline:
+// the epoch, the insertion proof in its tree, and the inputs of the lookup.
+// It lets anyone re-verify exactly what a client saw, long after the fact.
+type ProofBundle struct {
+	Version       int
+	BaseDomain    string
+	Email         string
+	Revision      int
+	SignedKeyList string
+	MinEpochID    int
+	VRFPublicKey  string
+	Epoch         *Epoch
+	Proof         *InsertionProof
+	// Time is the Unix time the client verified the lookup at, to replay
+	// the verification with VerifyBundle.
+	Time int64
+}
+
+// NewProofBundle creates a bundle of the current version.
+func NewProofBundle(
+	baseDomain string,
+	email string,
+	revision int,
+	signedKeyList string,
+	minEpochID int,
+	vrfPublicKeyBase64 string,
+	epoch *Epoch,
+	proof *InsertionProof,
+	verificationTime int64,
+) *ProofBundle {
+	return &ProofBundle{
+		Version:       proofBundleVersion,
+		BaseDomain:    baseDomain,
+		Email:         email,
+		Revision:      revision,
+		SignedKeyList: signedKeyList,
+		MinEpochID:    minEpochID,
+		VRFPublicKey:  vrfPublicKeyBase64,
+		Epoch:         epoch,
+		Proof:         proof,
+		Time:          verificationTime,
+	}
+}
+
+// VerifyBundle verifies the epoch of the bundle as VerifyEpoch does,
+// and the insertion proof of the lookup in the tree of the epoch.
+// Pass the Time of the bundle as currentUnixTime to replay the
+// verification as the client made it.
+func VerifyBundle(bundle *ProofBundle, currentUnixTime int64) error {
+	return VerifyBundleWithTranscript(bundle, currentUnixTime, nil)
+}
+
+// VerifyBundleWithTranscript is VerifyBundle, also recording the steps
+// of the verification in the transcript, whether it succeeds or not.
+func VerifyBundleWithTranscript(bundle *ProofBundle, currentUnixTime int64, transcript *Transcript) error {
+	err := verifyBundle(bundle, currentUnixTime, transcript)
+	if transcript != nil {
+		transcript.finish(err)
+	}
+
+	return err
+}
+
+func verifyBundle(bundle *ProofBundle, currentUnixTime int64, transcript *Transcript) error {
+	if err := bundle.check(); err != nil {
+		return err
+	}
+	// Bundles are only produced for the version 1 tree.
+	treeVersion := TreeVersion1
+	epochOptions := &EpochOptions{TreeVersion: &treeVersion, transcript: transcript} //nolint:exhaustruct
+	if _, err := VerifyEpochWithOptions(bundle.Epoch, bundle.BaseDomain, currentUnixTime, epochOptions); err != nil {
+		return err
+	}
+	step := transcript.begin("VRF public key")
+	step.detail("public key", bundle.VRFPublicKey)
+	publicKey, err := parseVRFPublicKey(bundle.VRFPublicKey)
+	if err = step.end(err); err != nil {
+		return errors.Wrap(err, "ktclient: VRF proof")
+	}
+
+	return verifyInsertionProof(
+		bundle.Email,
+		bundle.Revision,
+		bundle.SignedKeyList,
+		bundle.MinEpochID,
+		publicKey,
+		bundle.Epoch.TreeHash,
+		bundle.Proof,
+		&ProofOptions{TreeVersion: &treeVersion, transcript: transcript}, //nolint:exhaustruct
+	)
+}
+
+func (b *ProofBundle) check() error {
+	if b.Version != proofBundleVersion {
+		return fmt.Errorf("ktclient: %w: unsupported version %d", errProofBundle, b.Version)
+	}
+	if b.Epoch == nil || b.Proof == nil {
+		return fmt.Errorf("ktclient: %w: missing epoch or proof", errProofBundle)
+	}
+
+	return nil
+}
+
+// JSON encodes the bundle in JSON.
+func (b *ProofBundle) JSON() ([]byte, error) {
+	if err := b.check(); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "ktclient: encode proof bundle")
+	}
+
+	return data, nil
+}
+
+// Binary encodes the bundle in its compact binary form: the magic "KTPB",
+// then the fields in order, integers as varints, strings and byte strings
+// prefixed by their length, and the neighbours sorted by level.
+func (b *ProofBundle) Binary() ([]byte, error) {
+	if err := b.check(); err != nil {
+		return nil, err
+	}
+	var writer bundleWriter
+	writer.WriteString(proofBundleMagic)
+	writer.writeInt(int64(b.Version))
+	writer.writeString(b.BaseDomain)
+	writer.writeString(b.Email)
+	writer.writeInt(int64(b.Revision))
+	writer.writeString(b.SignedKeyList)
+	writer.writeInt(int64(b.MinEpochID))
+	writer.writeString(b.VRFPublicKey)
+	writer.writeInt(int64(b.Epoch.EpochID))
+	writer.writeString(b.Epoch.PreviousChainHash)
+	writer.writeString(b.Epoch.CertificateChain)
+	writer.writeInt(int64(b.Epoch.CertificateIssuer))
+	writer.writeString(b.Epoch.TreeHash)
+	writer.writeString(b.Epoch.ChainHash)
+	writer.writeInt(b.Epoch.CertificateTime)
+	writer.writeString(b.Epoch.Signature)
+	writer.writeString(b.Epoch.Cosignatures)
+	writer.writeInt(int64(b.Proof.ProofType))
+	writer.writeString(b.Proof.VRFProofHex)
+	levels := make([]int, 0, len(b.Proof.Neighbours))
+	for level := range b.Proof.Neighbours {
+		levels = append(levels, int(level))
+	}
+	sort.Ints(levels)
+	writer.writeInt(int64(len(levels)))
+	for _, level := range levels {
+		writer.writeInt(int64(level))
+		writer.writeString(string(b.Proof.Neighbours[uint8(level)]))
+	}
+	writer.writeInt(b.Time)
+
+	return writer.Bytes(), nil
+}
+
+// ParseProofBundle decodes a bundle in either its JSON or binary form.
+func ParseProofBundle(data []byte) (*ProofBundle, error) {
+	var bundle *ProofBundle
+	var err error
+	if bytes.HasPrefix(data, []byte(proofBundleMagic)) {
+		bundle, err = parseBinaryProofBundle(data[len(proofBundleMagic):])
+	} else {
+		err = json.Unmarshal(data, &bundle)
+		if err != nil {
+			err = fmt.Errorf("ktclient: %w: invalid JSON: %v", errProofBundle, err) //nolint:errorlint
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bundle == nil {
+		return nil, fmt.Errorf("ktclient: %w: empty bundle", errProofBundle)
+	}
+	if err := bundle.check(); err != nil {
+		return nil, err
+	}
+
+	return bundle, nil
+}
+
+func parseBinaryProofBundle(data []byte) (*ProofBundle, error) {
+	reader := &bundleReader{data: data}
+	bundle := &ProofBundle{ //nolint:exhaustruct
+		Epoch: &Epoch{},          //nolint:exhaustruct
+		Proof: &InsertionProof{}, //nolint:exhaustruct
+	}
+	bundle.Version = reader.readInt()
+	if reader.err == nil && bundle.Version != proofBundleVersion {
+		return nil, fmt.Errorf("ktclient: %w: unsupported version %d", errProofBundle, bundle.Version)
+	}
+	bundle.BaseDomain = reader.readString()
+	bundle.Email = reader.readString()
+	bundle.Revision = reader.readInt()
+	bundle.SignedKeyList = reader.readString()
+	bundle.MinEpochID = reader.readInt()
+	bundle.VRFPublicKey = reader.readString()
+	bundle.Epoch.EpochID = reader.readInt()
+	bundle.Epoch.PreviousChainHash = reader.readString()
+	bundle.Epoch.CertificateChain = reader.readString()
+	bundle.Epoch.CertificateIssuer = reader.readInt()
+	bundle.Epoch.TreeHash = reader.readString()
+	bundle.Epoch.ChainHash = reader.readString()
+	bundle.Epoch.CertificateTime = reader.readInt64()
+	bundle.Epoch.Signature = reader.readString()
+	bundle.Epoch.Cosignatures = reader.readString()
+	bundle.Proof.ProofType = reader.readInt()
+	bundle.Proof.VRFProofHex = reader.readString()
+	neighbours := reader.readInt()
+	if reader.err == nil && (neighbours < 0 || neighbours > 256) {
+		reader.fail("invalid neighbour count")
+	}
+	bundle.Proof.Neighbours = make(map[uint8][]byte)
+	previousLevel := -1
+	for i := 0; i < neighbours && reader.err == nil; i++ {
+		level := reader.readInt()
+		if level <= previousLevel || level > 255 {
+			reader.fail("invalid neighbour level")
+		}
+		bundle.Proof.Neighbours[uint8(level)] = []byte(reader.readString())
+		previousLevel = level
+	}
+	bundle.Time = reader.readInt64()
+	if reader.err == nil && len(reader.data) > 0 {
+		reader.fail("trailing data")
+	}
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	return bundle, nil
+}
+
+// bundleWriter writes the fields of the binary form of proof bundles.
+type bundleWriter struct {
+	bytes.Buffer
+}
+
+func (w *bundleWriter) writeInt(value int64) {
+	w.Write(binary.AppendVarint(nil, value))
+}
+
+func (w *bundleWriter) writeString(value string) {
+	w.writeInt(int64(len(value)))
+	w.WriteString(value)
+}
+
+// bundleReader reads the fields of the binary form of proof bundles.
+// After the first error, it only returns zero values.
+type bundleReader struct {
+	data []byte
+	err  error
+}
+
+func (r *bundleReader) fail(reason string) {
+	if r.err == nil {
+		r.err = fmt.Errorf("ktclient: %w: %s", errProofBundle, reason)
+	}
+}
+
+func (r *bundleReader) readInt64() int64 {
+	if r.err != nil {
+		return 0
+	}
+	value, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.fail("invalid integer")
+
+		return 0
+	}
+	r.data = r.data[n:]
+
+	return value
+}
+
+func (r *bundleReader) readInt() int {
+	value := r.readInt64()
+	if int64(int(value)) != value {
+		r.fail("integer out of range")
+
+		return 0
+	}
+
+	return int(value)
+}
+
+func (r *bundleReader) readString() string {
+	length := r.readInt64()
+	if r.err != nil {
+		return ""
+	}
+	if length < 0 || length > int64(len(r.data)) {
+		r.fail("invalid length")
+
+		return ""
+	}
+	value := string(r.data[:length])
+	r.data = r.data[length:]
+
+	return value
+}
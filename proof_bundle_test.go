@@ -0,0 +1,96 @@
+package ktclient
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProofBundle(t *testing.T) *ProofBundle {
+	t.Helper()
+	proof, err := newTestSKLProofData().getProof()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewProofBundle(
+		"dev.proton.wtf",
+		testSKLEmail,
+		1,
+		`[{"Primary":1,"Flags":3}]`,
+		46,
+		testVRFPublicKey,
+		newTestEpoch(),
+		proof,
+		1_689_062_800,
+	)
+}
+
+func TestProofBundleRoundTrip(t *testing.T) {
+	t.Parallel()
+	bundle := newTestProofBundle(t)
+	bundle.Epoch.Signature = "signature"
+	bundle.Epoch.Cosignatures = "— witness AAAA\n"
+
+	jsonData, err := bundle.JSON()
+	assert.NoError(t, err)
+	parsed, err := ParseProofBundle(jsonData)
+	assert.NoError(t, err)
+	assert.Equal(t, bundle, parsed)
+
+	binaryData, err := bundle.Binary()
+	assert.NoError(t, err)
+	assert.Less(t, len(binaryData), len(jsonData))
+	parsed, err = ParseProofBundle(binaryData)
+	assert.NoError(t, err)
+	assert.Equal(t, bundle, parsed)
+	encoded, err := parsed.Binary()
+	assert.NoError(t, err)
+	assert.Equal(t, binaryData, encoded)
+}
+
+func TestProofBundleParseErrors(t *testing.T) {
+	t.Parallel()
+	bundle := newTestProofBundle(t)
+	binaryData, err := bundle.Binary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle.Version = 2
+	futureJSON, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":          {},
+		"null":           []byte("null"),
+		"future version": futureJSON,
+		"no epoch":       []byte(`{"Version":1,"Proof":{}}`),
+		"truncated":      binaryData[:len(binaryData)-1],
+		"trailing data":  append(append([]byte(nil), binaryData...), 0),
+		"binary version": append([]byte(proofBundleMagic), 4),
+		"magic only":     []byte(proofBundleMagic),
+	} {
+		_, err := ParseProofBundle(data)
+		assert.True(t, errors.Is(err, errProofBundle), "%s: %v", name, err)
+	}
+}
+
+func TestVerifyBundle(t *testing.T) {
+	t.Parallel()
+	bundle := newTestProofBundle(t)
+	// The epoch verifies, but the proof was not produced in its tree.
+	err := VerifyBundle(bundle, bundle.Time)
+	assert.True(t, errors.Is(err, errIntegrity), err)
+
+	bundle.Epoch.CertificateTime++
+	err = VerifyBundle(bundle, bundle.Time)
+	assert.True(t, errors.Is(err, errCert), err)
+
+	bundle.Version = 0
+	err = VerifyBundle(bundle, bundle.Time)
+	assert.True(t, errors.Is(err, errProofBundle), err)
+}
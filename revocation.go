@@ -0,0 +1,163 @@
+package ktclient
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ocsp"
+)
+
+// RevocationPolicy selects how the revocation status of the epoch
+// certificate affects the verification of an epoch.
+type RevocationPolicy int
+
+const (
+	// RevocationIgnore does not check the revocation status.
+	RevocationIgnore RevocationPolicy = iota
+	// RevocationSoftFail rejects certificates that valid revocation
+	// information shows revoked, but accepts certificates whose revocation
+	// information is missing, invalid or outdated.
+	RevocationSoftFail
+	// RevocationHardFail requires valid revocation information showing
+	// that the certificate is not revoked.
+	RevocationHardFail
+)
+
+// RevocationOptions carries the revocation information of an epoch
+// certificate. The library does not fetch it: callers get it from the
+// CA, or from the server stapling it to the epoch.
+type RevocationOptions struct {
+	Policy RevocationPolicy
+	// OCSPResponse is a DER encoded OCSP response for the epoch certificate,
+	// signed by its issuer or by a responder the issuer delegated to.
+	OCSPResponse []byte
+	// CRL is a DER or PEM encoded certificate revocation list signed by
+	// the issuer of the epoch certificate.
+	CRL []byte
+}
+
+// verifyRevocation checks the revocation status of the leaf certificate of
+// the PEM encoded chain, against the issuer certificate that follows it.
+func verifyRevocation(certificateChain string, currentTime time.Time, options *RevocationOptions) error {
+	if options == nil || options.Policy == RevocationIgnore {
+		return nil
+	}
+	if options.Policy != RevocationSoftFail && options.Policy != RevocationHardFail {
+		return fmt.Errorf("ktclient: %w: unknown policy %d", errRevocation, options.Policy)
+	}
+	cert, issuer, err := parseLeafAndIssuer(certificateChain)
+	if err != nil {
+		return err
+	}
+	var failures []error
+	checked := false
+	for _, check := range []func() error{
+		func() error { return verifyOCSPResponse(cert, issuer, options.OCSPResponse, currentTime) },
+		func() error { return verifyCRL(cert, issuer, options.CRL, currentTime) },
+	} {
+		err := check()
+		switch {
+		case err == nil:
+			checked = true
+		case errors.Is(err, errRevoked):
+			return err
+		case !errors.Is(err, errNoRevocationInfo):
+			failures = append(failures, err)
+		}
+	}
+	if checked || options.Policy == RevocationSoftFail {
+		return nil
+	}
+	if len(failures) > 0 {
+		return failures[0]
+	}
+
+	return fmt.Errorf("ktclient: %w: no OCSP response or CRL provided", errRevocation)
+}
+
+// verifyOCSPResponse returns nil if the OCSP response shows the certificate
+// is good, errRevoked if it shows it revoked, and errNoRevocationInfo if
+// there is no response.
+func verifyOCSPResponse(cert, issuer *x509.Certificate, response []byte, currentTime time.Time) error {
+	if len(response) == 0 {
+		return errNoRevocationInfo
+	}
+	parsed, err := ocsp.ParseResponseForCert(response, cert, issuer)
+	if err != nil {
+		return fmt.Errorf("ktclient: %w: invalid OCSP response: %v", errRevocation, err) //nolint:errorlint
+	}
+	if parsed.Status == ocsp.Revoked {
+		return fmt.Errorf("ktclient: %w: OCSP response revokes the certificate on %v", errRevoked, parsed.RevokedAt)
+	}
+	if parsed.Status != ocsp.Good {
+		return fmt.Errorf("ktclient: %w: OCSP status unknown", errRevocation)
+	}
+
+	return checkUpdateWindow("OCSP response", parsed.ThisUpdate, parsed.NextUpdate, currentTime)
+}
+
+// verifyCRL returns nil if the CRL does not list the certificate,
+// errRevoked if it does, and errNoRevocationInfo if there is no CRL.
+func verifyCRL(cert, issuer *x509.Certificate, crl []byte, currentTime time.Time) error {
+	if len(crl) == 0 {
+		return errNoRevocationInfo
+	}
+	if block, _ := pem.Decode(crl); block != nil {
+		crl = block.Bytes
+	}
+	list, err := x509.ParseRevocationList(crl)
+	if err != nil {
+		return fmt.Errorf("ktclient: %w: invalid CRL: %v", errRevocation, err) //nolint:errorlint
+	}
+	if !bytes.Equal(list.RawIssuer, issuer.RawSubject) {
+		return fmt.Errorf("ktclient: %w: CRL of another issuer", errRevocation)
+	}
+	if err := list.CheckSignatureFrom(issuer); err != nil {
+		return fmt.Errorf("ktclient: %w: invalid CRL signature: %v", errRevocation, err) //nolint:errorlint
+	}
+	for _, revoked := range list.RevokedCertificates { //nolint:staticcheck
+		if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return fmt.Errorf("ktclient: %w: CRL revokes the certificate on %v", errRevoked, revoked.RevocationTime)
+		}
+	}
+
+	return checkUpdateWindow("CRL", list.ThisUpdate, list.NextUpdate, currentTime)
+}
+
+// checkUpdateWindow checks that revocation information issued at thisUpdate
+// is still current. A zero nextUpdate means that newer information is
+// always available, so only information issued before now is accepted.
+func checkUpdateWindow(name string, thisUpdate, nextUpdate, currentTime time.Time) error {
+	if currentTime.Before(thisUpdate) {
+		return fmt.Errorf("ktclient: %w: %s issued in the future", errRevocation, name)
+	}
+	if !nextUpdate.IsZero() && currentTime.After(nextUpdate) {
+		return fmt.Errorf("ktclient: %w: %s outdated since %v", errRevocation, name, nextUpdate)
+	}
+
+	return nil
+}
+
+// parseLeafAndIssuer parses the first two certificates of a PEM encoded chain.
+func parseLeafAndIssuer(certificateChain string) (*x509.Certificate, *x509.Certificate, error) {
+	rest := []byte(certificateChain)
+	certs := make([]*x509.Certificate, 0, 2)
+	for len(certs) < 2 {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, nil, fmt.Errorf("ktclient: %w: missing issuer certificate", errCert)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "ktclient: cannot parse cert")
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs[0], certs[1], nil
+}
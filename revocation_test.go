@@ -0,0 +1,178 @@
+package ktclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ocsp"
+)
+
+var testRevocationTime = time.Unix(1_689_062_740, 0)
+
+type testRevocationCA struct {
+	cert  *x509.Certificate
+	key   *ecdsa.PrivateKey
+	leaf  *x509.Certificate
+	chain string
+}
+
+func newTestRevocationCA(t *testing.T, name string) *testRevocationCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{ //nolint:exhaustruct
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name}, //nolint:exhaustruct
+		SubjectKeyId:          []byte(name),
+		NotBefore:             testRevocationTime.Add(-time.Hour),
+		NotAfter:              testRevocationTime.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTemplate := &x509.Certificate{ //nolint:exhaustruct
+		SerialNumber: big.NewInt(46),
+		Subject:      pkix.Name{CommonName: testEpochSubjectName}, //nolint:exhaustruct
+		NotBefore:    testRevocationTime.Add(-time.Hour),
+		NotAfter:     testRevocationTime.Add(time.Hour),
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, cert, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(leafDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+
+	return &testRevocationCA{cert: cert, key: key, leaf: leaf, chain: chain}
+}
+
+func (ca *testRevocationCA) ocspResponse(t *testing.T, status int) []byte {
+	t.Helper()
+	response, err := ocsp.CreateResponse(ca.cert, ca.cert, ocsp.Response{ //nolint:exhaustruct
+		Status:       status,
+		SerialNumber: ca.leaf.SerialNumber,
+		ThisUpdate:   testRevocationTime.Add(-time.Minute),
+		NextUpdate:   testRevocationTime.Add(time.Minute),
+		RevokedAt:    testRevocationTime.Add(-time.Minute),
+	}, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return response
+}
+
+func (ca *testRevocationCA) crl(t *testing.T, revoked ...*big.Int) []byte {
+	t.Helper()
+	template := &x509.RevocationList{ //nolint:exhaustruct
+		Number:     big.NewInt(1),
+		ThisUpdate: testRevocationTime.Add(-time.Minute),
+		NextUpdate: testRevocationTime.Add(time.Minute),
+	}
+	for _, serial := range revoked {
+		template.RevokedCertificates = append( //nolint:staticcheck
+			template.RevokedCertificates, //nolint:staticcheck
+			pkix.RevokedCertificate{SerialNumber: serial, RevocationTime: testRevocationTime}, //nolint:exhaustruct
+		)
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, template, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl})
+}
+
+func TestRevocationPolicies(t *testing.T) {
+	t.Parallel()
+	ca, other := newTestRevocationCA(t, "Test CA"), newTestRevocationCA(t, "Other CA")
+	good, revoked := ca.ocspResponse(t, ocsp.Good), ca.ocspResponse(t, ocsp.Revoked)
+	forged := other.ocspResponse(t, ocsp.Good)
+	crl, revokingCRL := ca.crl(t, big.NewInt(7)), ca.crl(t, ca.leaf.SerialNumber)
+	otherCRL := other.crl(t)
+
+	for name, testCase := range map[string]struct {
+		policy       RevocationPolicy
+		ocspResponse []byte
+		crl          []byte
+		expected     error
+	}{
+		"ignore revoked":          {RevocationIgnore, revoked, revokingCRL, nil},
+		"soft fail missing":       {RevocationSoftFail, nil, nil, nil},
+		"soft fail forged":        {RevocationSoftFail, forged, otherCRL, nil},
+		"soft fail good":          {RevocationSoftFail, good, nil, nil},
+		"soft fail OCSP revoked":  {RevocationSoftFail, revoked, crl, errRevoked},
+		"soft fail CRL revoked":   {RevocationSoftFail, good, revokingCRL, errRevoked},
+		"hard fail missing":       {RevocationHardFail, nil, nil, errRevocation},
+		"hard fail forged OCSP":   {RevocationHardFail, forged, nil, errRevocation},
+		"hard fail other CRL":     {RevocationHardFail, nil, otherCRL, errRevocation},
+		"hard fail good OCSP":     {RevocationHardFail, good, nil, nil},
+		"hard fail good CRL":      {RevocationHardFail, nil, crl, nil},
+		"hard fail one forged":    {RevocationHardFail, forged, crl, nil},
+		"hard fail OCSP revoked":  {RevocationHardFail, revoked, nil, errRevoked},
+		"hard fail CRL revoked":   {RevocationHardFail, nil, revokingCRL, errRevoked},
+		"unknown policy":          {RevocationHardFail + 1, good, crl, errRevocation},
+		"hard fail invalid bytes": {RevocationHardFail, []byte("OCSP"), []byte("CRL"), errRevocation},
+	} {
+		options := &RevocationOptions{Policy: testCase.policy, OCSPResponse: testCase.ocspResponse, CRL: testCase.crl}
+		err := verifyRevocation(ca.chain, testRevocationTime, options)
+		if testCase.expected == nil {
+			assert.NoError(t, err, name)
+		} else {
+			assert.True(t, errors.Is(err, testCase.expected), "%s: %v", name, err)
+		}
+	}
+}
+
+func TestRevocationOutdated(t *testing.T) {
+	t.Parallel()
+	ca := newTestRevocationCA(t, "Test CA")
+	options := &RevocationOptions{ //nolint:exhaustruct
+		Policy:       RevocationHardFail,
+		OCSPResponse: ca.ocspResponse(t, ocsp.Good),
+	}
+	assert.NoError(t, verifyRevocation(ca.chain, testRevocationTime, options))
+	err := verifyRevocation(ca.chain, testRevocationTime.Add(time.Hour), options)
+	assert.True(t, errors.Is(err, errRevocation))
+	assert.True(t, strings.Contains(err.Error(), "outdated"))
+	err = verifyRevocation(ca.chain, testRevocationTime.Add(-time.Hour), options)
+	assert.True(t, errors.Is(err, errRevocation))
+
+	options.Policy = RevocationSoftFail
+	assert.NoError(t, verifyRevocation(ca.chain, testRevocationTime.Add(time.Hour), options))
+}
+
+func TestVerifyEpochRevocation(t *testing.T) {
+	t.Parallel()
+	options := &EpochOptions{Revocation: &RevocationOptions{Policy: RevocationSoftFail}} //nolint:exhaustruct
+	_, err := VerifyEpochWithOptions(newTestEpoch(), "dev.proton.wtf", 1_689_062_740, options)
+	assert.NoError(t, err)
+
+	options.Revocation.Policy = RevocationHardFail
+	_, err = VerifyEpochWithOptions(newTestEpoch(), "dev.proton.wtf", 1_689_062_740, options)
+	assert.True(t, errors.Is(err, errRevocation))
+}
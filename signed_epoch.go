@@ -0,0 +1,168 @@
+package ktclient
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	_ "crypto/sha256" // SHA-256 digests of P-256 signatures.
+	_ "crypto/sha512" // SHA-384 and SHA-512 digests of P-384 and P-521 signatures.
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// signedEpochContext prefixes the epoch name in the signed data,
+// so that epoch signatures cannot be mistaken for other signatures.
+const signedEpochContext = "pm-key-transparency signed epoch\n"
+
+// EpochSigningKey is the pinned public key of a key transparency server
+// that signs its epochs itself, for private deployments which cannot get
+// publicly-trusted certificates.
+type EpochSigningKey struct {
+	publicKey crypto.PublicKey
+}
+
+// ParseEpochSigningKey parses a PEM encoded Ed25519 or ECDSA public key.
+// ECDSA keys must be on the P-256, P-384 or P-521 curve, and sign the
+// SHA-256, SHA-384 or SHA-512 digest respectively.
+func ParseEpochSigningKey(publicKeyPEM string) (*EpochSigningKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("ktclient: %w: cannot decode PEM", errSigningKey)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "ktclient: cannot parse epoch signing key")
+	}
+	switch publicKey := publicKey.(type) {
+	case ed25519.PublicKey:
+		return &EpochSigningKey{publicKey: publicKey}, nil
+	case *ecdsa.PublicKey:
+		if _, err := ecdsaDigestHash(publicKey.Curve); err != nil {
+			return nil, err
+		}
+
+		return &EpochSigningKey{publicKey: publicKey}, nil
+	default:
+		return nil, fmt.Errorf("ktclient: %w: unsupported key type %T", errSigningKey, publicKey)
+	}
+}
+
+// VerifySignedEpoch verifies an epoch of a deployment which signs its
+// epochs with the given key instead of obtaining epoch certificates.
+// The epoch's Signature must be the base64 signature of its epoch name,
+// the alternate name epoch certificates would have. The chain hash is
+// verified as by VerifyEpoch, which never accepts signed epochs.
+// Options which only apply to epoch certificates are rejected.
+// It returns the epoch's CertificateTime.
+func VerifySignedEpoch(
+	epoch *Epoch,
+	baseDomain string,
+	currentUnixTime int64,
+	signingKey *EpochSigningKey,
+	options *EpochOptions,
+) (int64, error) {
+	if signingKey == nil {
+		return 0, fmt.Errorf("ktclient: %w: no epoch signing key", errSigningKey)
+	}
+	if option := certificateOption(options); option != "" {
+		return 0, fmt.Errorf("ktclient: %w: signed epochs have no certificate for %s", errSigningKey, option)
+	}
+
+	return verifyEpoch(epoch, baseDomain, currentUnixTime, options, signingKey.verifyAttestation)
+}
+
+// certificateOption returns the name of an option set which only applies
+// to epoch certificates, or "" if there is none.
+func certificateOption(options *EpochOptions) string {
+	switch {
+	case options == nil:
+		return ""
+	case options.CTLogs != nil:
+		return "CTLogs"
+	case options.CTLogPublicKeys != nil:
+		return "CTLogPublicKeys"
+	case options.STHStore != nil:
+		return "STHStore"
+	case options.Revocation != nil:
+		return "Revocation"
+	case options.StrictAlternateNames:
+		return "StrictAlternateNames"
+	case options.Freshness != nil && options.Freshness.MaxCertificateDelay != 0:
+		return "Freshness.MaxCertificateDelay"
+	default:
+		return ""
+	}
+}
+
+// ecdsaDigestHash returns the hash of the digests signed with keys on the curve.
+func ecdsaDigestHash(curve elliptic.Curve) (crypto.Hash, error) {
+	switch curve {
+	case elliptic.P256():
+		return crypto.SHA256, nil
+	case elliptic.P384():
+		return crypto.SHA384, nil
+	case elliptic.P521():
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("ktclient: %w: unsupported curve %s", errSigningKey, curve.Params().Name)
+	}
+}
+
+// verifyAttestation verifies the signature of the epoch. As there is no
+// certificate validity to bound it, the CertificateTime must not be in the
+// future, up to the MaxClockSkew of the freshness policy.
+func (k *EpochSigningKey) verifyAttestation(epoch *attestedEpoch, clock Clock, options *EpochOptions) (int64, error) {
+	step := options.transcript.begin("epoch signature")
+	step.detail("epoch name", epoch.name())
+	step.detailf("key type", "%T", k.publicKey)
+	step.detail("current time", clock.Now().Unix())
+
+	err := k.verifySignature(epoch)
+	if err == nil {
+		var maxClockSkew time.Duration
+		if options.Freshness != nil {
+			maxClockSkew = options.Freshness.MaxClockSkew
+		}
+		certificateTime := time.Unix(epoch.epoch.CertificateTime, 0)
+		if certificateTime.After(clock.Now().Add(maxClockSkew)) {
+			err = fmt.Errorf("ktclient: %w: certificate time %v in the future", errFreshness, certificateTime)
+		}
+	}
+	if err = step.end(err); err != nil {
+		return 0, err
+	}
+
+	return epoch.epoch.CertificateTime, nil
+}
+
+func (k *EpochSigningKey) verifySignature(epoch *attestedEpoch) error {
+	signature, err := base64.StdEncoding.DecodeString(epoch.epoch.Signature)
+	if err != nil {
+		return errors.Wrap(err, "ktclient: invalid encoding of epoch signature")
+	}
+	message := []byte(signedEpochContext + epoch.name())
+	var verified bool
+	switch publicKey := k.publicKey.(type) {
+	case ed25519.PublicKey:
+		verified = ed25519.Verify(publicKey, message, signature)
+	case *ecdsa.PublicKey:
+		digestHash, err := ecdsaDigestHash(publicKey.Curve)
+		if err != nil {
+			return err
+		}
+		digest := digestHash.New()
+		digest.Write(message) //nolint:errcheck
+		verified = ecdsa.VerifyASN1(publicKey, digest.Sum(nil), signature)
+	}
+	if !verified {
+		return fmt.Errorf("ktclient: %w: invalid epoch signature", errSigningKey)
+	}
+
+	return nil
+}
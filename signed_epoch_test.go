@@ -0,0 +1,181 @@
+package ktclient
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSignedEpochName = "506062a81b4f2ae8aeb2f6dd2d003ada.c3cd1e84a39d19225a8653d1012cf0d2.1689062740.46.1.kt.internal"
+
+func newTestSignedEpoch() *Epoch {
+	return &Epoch{ //nolint:exhaustruct
+		EpochID:           46,
+		PreviousChainHash: "9624e880fe4b49b45fc15ca7e16b7ed8a846724a1802b2a2ca9d749770b00f4b",
+		TreeHash:          "65e8dd7b133f7a02fe0e249928f7fdf1a21df8a5923234c97e0177f0f56a194c",
+		ChainHash:         "506062a81b4f2ae8aeb2f6dd2d003adac3cd1e84a39d19225a8653d1012cf0d2",
+		CertificateTime:   1_689_062_740,
+	}
+}
+
+func encodeTestPublicKey(t *testing.T, publicKey crypto.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})) //nolint:exhaustruct
+}
+
+func TestSignedEpoch(t *testing.T) {
+	t.Parallel()
+	message := []byte(signedEpochContext + testSignedEpochName)
+	edPublicKey, edPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	ecPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	digest := sha256.Sum256(message)
+	ecSignature, err := ecdsa.SignASN1(rand.Reader, ecPrivateKey, digest[:])
+	assert.NoError(t, err)
+	p384PrivateKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.NoError(t, err)
+	p384Digest := sha512.Sum384(message)
+	p384Signature, err := ecdsa.SignASN1(rand.Reader, p384PrivateKey, p384Digest[:])
+	assert.NoError(t, err)
+
+	for name, testCase := range map[string]struct {
+		publicKey crypto.PublicKey
+		signature []byte
+	}{
+		"ed25519":     {edPublicKey, ed25519.Sign(edPrivateKey, message)},
+		"ecdsa":       {&ecPrivateKey.PublicKey, ecSignature},
+		"ecdsa P-384": {&p384PrivateKey.PublicKey, p384Signature},
+	} {
+		signingKey, err := ParseEpochSigningKey(encodeTestPublicKey(t, testCase.publicKey))
+		assert.NoError(t, err, name)
+		epoch := newTestSignedEpoch()
+		epoch.Signature = base64.StdEncoding.EncodeToString(testCase.signature)
+		certificateTime, err := VerifySignedEpoch(epoch, "kt.internal", 0, signingKey, nil)
+		assert.NoError(t, err, name)
+		assert.Equal(t, epoch.CertificateTime, certificateTime)
+
+		_, err = VerifySignedEpoch(epoch, "dev.proton.wtf", 0, signingKey, nil)
+		assert.True(t, errors.Is(err, errSigningKey), name)
+		legacyOptions := &EpochOptions{TreeVersion: testTreeVersion(TreeVersion0)} //nolint:exhaustruct
+		_, err = VerifySignedEpoch(epoch, "kt.internal", 0, signingKey, legacyOptions)
+		assert.True(t, errors.Is(err, errSigningKey), name)
+		tampered := *epoch
+		tampered.CertificateTime++
+		_, err = VerifySignedEpoch(&tampered, "kt.internal", 0, signingKey, nil)
+		assert.True(t, errors.Is(err, errSigningKey), name)
+		tampered = *epoch
+		tampered.TreeHash = tampered.PreviousChainHash
+		_, err = VerifySignedEpoch(&tampered, "kt.internal", 0, signingKey, nil)
+		assert.True(t, errors.Is(err, errIntegrity), name)
+	}
+
+	// P-384 keys sign SHA-384 digests.
+	p384Key, err := ParseEpochSigningKey(encodeTestPublicKey(t, &p384PrivateKey.PublicKey))
+	assert.NoError(t, err)
+	p384SHA256Signature, err := ecdsa.SignASN1(rand.Reader, p384PrivateKey, digest[:])
+	assert.NoError(t, err)
+	epoch := newTestSignedEpoch()
+	epoch.Signature = base64.StdEncoding.EncodeToString(p384SHA256Signature)
+	_, err = VerifySignedEpoch(epoch, "kt.internal", 0, p384Key, nil)
+	assert.True(t, errors.Is(err, errSigningKey), err)
+}
+
+func TestSignedEpochInTheFuture(t *testing.T) {
+	t.Parallel()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	signingKey, err := ParseEpochSigningKey(encodeTestPublicKey(t, publicKey))
+	assert.NoError(t, err)
+	epoch := newTestSignedEpoch()
+	epoch.Signature = base64.StdEncoding.EncodeToString(
+		ed25519.Sign(privateKey, []byte(signedEpochContext+testSignedEpochName)),
+	)
+
+	early := epoch.CertificateTime - 3_600
+	_, err = VerifySignedEpoch(epoch, "kt.internal", early, signingKey, nil)
+	assert.True(t, errors.Is(err, errFreshness), err)
+	_, err = VerifySignedEpoch(epoch, "kt.internal", 0, signingKey, &EpochOptions{ //nolint:exhaustruct
+		Clock: NewFakeClock(time.Unix(early, 0)),
+	})
+	assert.True(t, errors.Is(err, errFreshness), err)
+	_, err = VerifySignedEpoch(epoch, "kt.internal", early, signingKey, &EpochOptions{ //nolint:exhaustruct
+		Freshness: &FreshnessPolicy{MaxClockSkew: 2 * time.Hour}, //nolint:exhaustruct
+	})
+	assert.NoError(t, err)
+}
+
+func TestSignedEpochCannotBeDowngradedInto(t *testing.T) {
+	t.Parallel()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	signingKey, err := ParseEpochSigningKey(encodeTestPublicKey(t, publicKey))
+	assert.NoError(t, err)
+	epoch := newTestSignedEpoch()
+	epoch.Signature = base64.StdEncoding.EncodeToString(
+		ed25519.Sign(privateKey, []byte(signedEpochContext+testSignedEpochName)),
+	)
+
+	_, err = VerifyEpoch(epoch, "kt.internal", 0)
+	assert.Error(t, err, "the production mode must ignore epoch signatures")
+	_, err = VerifySignedEpoch(epoch, "kt.internal", 0, nil, nil)
+	assert.True(t, errors.Is(err, errSigningKey))
+	for name, options := range map[string]*EpochOptions{
+		"CTLogs":               {CTLogs: map[string]CTLogProofSource{}},                       //nolint:exhaustruct
+		"CTLogPublicKeys":      {CTLogPublicKeys: map[string]string{}},                        //nolint:exhaustruct
+		"STHStore":             {STHStore: newSTHStoreWithKeys(nil)},                          //nolint:exhaustruct
+		"Revocation":           {Revocation: &RevocationOptions{}},                            //nolint:exhaustruct
+		"StrictAlternateNames": {StrictAlternateNames: true},                                  //nolint:exhaustruct
+		"Freshness":            {Freshness: &FreshnessPolicy{MaxCertificateDelay: time.Hour}}, //nolint:exhaustruct
+	} {
+		_, err = VerifySignedEpoch(epoch, "kt.internal", 0, signingKey, options)
+		assert.True(t, errors.Is(err, errSigningKey), name)
+	}
+	// The epoch age applies to signed epochs.
+	_, err = VerifySignedEpoch(epoch, "kt.internal", 0, signingKey, &EpochOptions{ //nolint:exhaustruct
+		Freshness: &FreshnessPolicy{MaxEpochAge: time.Hour}, //nolint:exhaustruct
+		Clock:     NewFakeClock(time.Unix(epoch.CertificateTime, 0).Add(2 * time.Hour)),
+	})
+	assert.True(t, errors.Is(err, ErrStaleEpoch), err)
+
+	otherPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	otherKey, err := ParseEpochSigningKey(encodeTestPublicKey(t, otherPublicKey))
+	assert.NoError(t, err)
+	_, err = VerifySignedEpoch(epoch, "kt.internal", 0, otherKey, nil)
+	assert.True(t, errors.Is(err, errSigningKey))
+}
+
+func TestParseEpochSigningKeyErrors(t *testing.T) {
+	t.Parallel()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	_, err = ParseEpochSigningKey(encodeTestPublicKey(t, &rsaKey.PublicKey))
+	assert.True(t, errors.Is(err, errSigningKey))
+	p224Key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	assert.NoError(t, err)
+	_, err = ParseEpochSigningKey(encodeTestPublicKey(t, &p224Key.PublicKey))
+	assert.True(t, errors.Is(err, errSigningKey), err)
+	_, err = ParseEpochSigningKey("not a key")
+	assert.True(t, errors.Is(err, errSigningKey))
+	_, err = ParseEpochSigningKey(certificateChain)
+	assert.Error(t, err)
+}
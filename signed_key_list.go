@@ -0,0 +1,242 @@
+package ktclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/pkg/errors"
+)
+
+// Flags of the keys of a signed key list.
+const (
+	// KeyFlagNotCompromised is set on keys which can verify signatures.
+	KeyFlagNotCompromised = 1
+	// KeyFlagNotObsolete is set on keys which can encrypt.
+	KeyFlagNotObsolete = 2
+	// KeyFlagEmailNoEncrypt is set on keys which must not encrypt emails.
+	KeyFlagEmailNoEncrypt = 4
+	// KeyFlagEmailNoSign is set on keys which must not sign emails.
+	KeyFlagEmailNoSign = 8
+
+	knownKeyFlags = KeyFlagNotCompromised | KeyFlagNotObsolete | KeyFlagEmailNoEncrypt | KeyFlagEmailNoSign
+)
+
+// SignedKeyListEntry describes an OpenPGP key of an address in a signed key list.
+type SignedKeyListEntry struct {
+	// Primary is 1 for the primary key of the address, 0 otherwise.
+	Primary int
+	Flags   int
+	// Fingerprint is the hex encoded fingerprint of the primary key of the OpenPGP key.
+	Fingerprint string
+	// SHA256Fingerprints are the hex encoded SHA-256 fingerprints of the
+	// primary key and of the subkeys of the OpenPGP key, in order.
+	SHA256Fingerprints []string
+}
+
+// SignedKeyList is the list of the keys of an address, which is the data of
+// the signed key lists inserted in the tree.
+type SignedKeyList []SignedKeyListEntry
+
+// AddressKey is an OpenPGP public key of an address, with the properties
+// it was served with.
+type AddressKey struct {
+	// PublicKey is the armored OpenPGP public key.
+	PublicKey string
+	Primary   bool
+	Flags     int
+}
+
+// signedKeyListEntryJSON requires every field of an entry to be present.
+type signedKeyListEntryJSON struct {
+	Primary            *int
+	Flags              *int
+	Fingerprint        *string
+	SHA256Fingerprints *[]string
+}
+
+// ParseSignedKeyList parses the data of a signed key list strictly: unknown
+// or missing fields, unknown flags, badly encoded or duplicate fingerprints
+// are rejected, and there must be exactly one primary key, which can verify
+// signatures and encrypt.
+func ParseSignedKeyList(data string) (SignedKeyList, error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var entries []signedKeyListEntryJSON
+	if err := decoder.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("ktclient: %w: %v", errSignedKeyList, err) //nolint:errorlint
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("ktclient: %w: trailing data", errSignedKeyList)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("ktclient: %w: no key", errSignedKeyList)
+	}
+
+	list := make(SignedKeyList, 0, len(entries))
+	primaryKeys := 0
+	fingerprints := make(map[string]bool)
+	for i, raw := range entries {
+		if raw.Primary == nil || raw.Flags == nil || raw.Fingerprint == nil || raw.SHA256Fingerprints == nil {
+			return nil, fmt.Errorf("ktclient: %w: key %d has missing fields", errSignedKeyList, i)
+		}
+		entry := SignedKeyListEntry{
+			Primary:            *raw.Primary,
+			Flags:              *raw.Flags,
+			Fingerprint:        *raw.Fingerprint,
+			SHA256Fingerprints: *raw.SHA256Fingerprints,
+		}
+		if err := entry.validate(); err != nil {
+			return nil, fmt.Errorf("ktclient: %w: key %d: %v", errSignedKeyList, i, err) //nolint:errorlint
+		}
+		if fingerprints[entry.Fingerprint] {
+			return nil, fmt.Errorf("ktclient: %w: duplicate key %s", errSignedKeyList, entry.Fingerprint)
+		}
+		fingerprints[entry.Fingerprint] = true
+		primaryKeys += entry.Primary
+		list = append(list, entry)
+	}
+	if primaryKeys != 1 {
+		return nil, fmt.Errorf("ktclient: %w: %d primary keys", errSignedKeyList, primaryKeys)
+	}
+
+	return list, nil
+}
+
+func (e *SignedKeyListEntry) validate() error {
+	if e.Primary != 0 && e.Primary != 1 {
+		return fmt.Errorf("invalid primary value %d", e.Primary) //nolint:goerr113
+	}
+	if e.Flags&^knownKeyFlags != 0 {
+		return fmt.Errorf("unknown flags %d", e.Flags) //nolint:goerr113
+	}
+	if e.Flags&KeyFlagNotObsolete != 0 && e.Flags&KeyFlagNotCompromised == 0 {
+		return errors.New("compromised key is not obsolete")
+	}
+	if e.Primary == 1 && e.Flags&(KeyFlagNotCompromised|KeyFlagNotObsolete) != KeyFlagNotCompromised|KeyFlagNotObsolete {
+		return errors.New("primary key is compromised or obsolete")
+	}
+	if !isLowerHex(e.Fingerprint, 20) {
+		return fmt.Errorf("invalid fingerprint %q", e.Fingerprint) //nolint:goerr113
+	}
+	if len(e.SHA256Fingerprints) == 0 {
+		return errors.New("no SHA-256 fingerprint")
+	}
+	for _, fingerprint := range e.SHA256Fingerprints {
+		if !isLowerHex(fingerprint, sha256.Size) {
+			return fmt.Errorf("invalid SHA-256 fingerprint %q", fingerprint) //nolint:goerr113
+		}
+	}
+
+	return nil
+}
+
+// isLowerHex tells whether s is the lowercase hex encoding of size bytes.
+func isLowerHex(s string, size int) bool {
+	decoded, err := hex.DecodeString(s)
+
+	return err == nil && len(decoded) == size && s == strings.ToLower(s)
+}
+
+// PrimaryKey returns the entry of the primary key of the list.
+func (l SignedKeyList) PrimaryKey() *SignedKeyListEntry {
+	for i := range l {
+		if l[i].Primary == 1 {
+			return &l[i]
+		}
+	}
+
+	return nil
+}
+
+// MatchKeys checks that the keys are exactly the keys of the list: each key
+// has an entry with its fingerprints, its primary status and its flags, and
+// each entry has a key.
+func (l SignedKeyList) MatchKeys(keys []AddressKey) error {
+	if len(keys) != len(l) {
+		return fmt.Errorf("ktclient: %w: %d keys for %d entries", errKeyMismatch, len(keys), len(l))
+	}
+	entries := make(map[string]*SignedKeyListEntry, len(l))
+	for i := range l {
+		entries[l[i].Fingerprint] = &l[i]
+	}
+	for i, key := range keys {
+		entity, err := readPublicKey(key.PublicKey)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("ktclient: key %d", i))
+		}
+		fingerprint := hex.EncodeToString(entity.PrimaryKey.Fingerprint)
+		entry, ok := entries[fingerprint]
+		if !ok {
+			return fmt.Errorf("ktclient: %w: key %s is not in the list", errKeyMismatch, fingerprint)
+		}
+		delete(entries, fingerprint)
+		if err := entry.matchKey(entity, &key); err != nil {
+			return fmt.Errorf("ktclient: %w: key %s: %v", errKeyMismatch, fingerprint, err) //nolint:errorlint
+		}
+	}
+
+	return nil
+}
+
+func (e *SignedKeyListEntry) matchKey(entity *openpgp.Entity, key *AddressKey) error {
+	if key.Primary != (e.Primary == 1) {
+		return errors.New("primary status differs")
+	}
+	if key.Flags != e.Flags {
+		return fmt.Errorf("flags %d, listed with %d", key.Flags, e.Flags) //nolint:goerr113
+	}
+	fingerprints, err := sha256Fingerprints(entity)
+	if err != nil {
+		return err
+	}
+	if strings.Join(fingerprints, ",") != strings.Join(e.SHA256Fingerprints, ",") {
+		return errors.New("SHA-256 fingerprints differ")
+	}
+
+	return nil
+}
+
+// readPublicKey reads a single armored OpenPGP public key.
+func readPublicKey(armoredKey string) (*openpgp.Entity, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredKey))
+	if err != nil {
+		return nil, fmt.Errorf("ktclient: %w: %v", errOpenPGPKey, err) //nolint:errorlint
+	}
+	if len(entities) != 1 {
+		return nil, fmt.Errorf("ktclient: %w: %d keys", errOpenPGPKey, len(entities))
+	}
+	if entities[0].PrivateKey != nil {
+		return nil, fmt.Errorf("ktclient: %w: private key", errOpenPGPKey)
+	}
+
+	return entities[0], nil
+}
+
+// sha256Fingerprints returns the SHA-256 fingerprints of the primary key
+// and of the subkeys of the entity: the SHA-256 hash of the data hashed for
+// their fingerprint.
+func sha256Fingerprints(entity *openpgp.Entity) ([]string, error) {
+	fingerprints := make([]string, 0, 1+len(entity.Subkeys))
+	var buffer bytes.Buffer
+	if err := entity.PrimaryKey.SerializeForHash(&buffer); err != nil {
+		return nil, errors.Wrap(err, "ktclient: serialize key")
+	}
+	hash := sha256.Sum256(buffer.Bytes())
+	fingerprints = append(fingerprints, hex.EncodeToString(hash[:]))
+	for _, subkey := range entity.Subkeys {
+		buffer.Reset()
+		if err := subkey.PublicKey.SerializeForHash(&buffer); err != nil {
+			return nil, errors.Wrap(err, "ktclient: serialize key")
+		}
+		hash := sha256.Sum256(buffer.Bytes())
+		fingerprints = append(fingerprints, hex.EncodeToString(hash[:]))
+	}
+
+	return fingerprints, nil
+}
@@ -0,0 +1,79 @@
+package ktclient
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+)
+
+// VerifySignedKeyListSignature checks that signature, an armored detached
+// OpenPGP signature, signs the signed key list data with primaryKey, the
+// armored public key of the address. The signing key must be the primary key
+// of the list. The signature and the key must be valid at currentUnixTime,
+// the current time if it is not positive.
+func VerifySignedKeyListSignature(
+	signedKeyList string,
+	signature string,
+	primaryKey string,
+	currentUnixTime int64,
+) error {
+	list, err := ParseSignedKeyList(signedKeyList)
+	if err != nil {
+		return err
+	}
+	entity, err := readPublicKey(primaryKey)
+	if err != nil {
+		return err
+	}
+	fingerprint := hex.EncodeToString(entity.PrimaryKey.Fingerprint)
+	if fingerprint != list.PrimaryKey().Fingerprint {
+		return fmt.Errorf(
+			"ktclient: %w: key %s is not the primary key of the list",
+			errSignedKeyListSignature, fingerprint,
+		)
+	}
+	clock := unixTimeClock(currentUnixTime)
+	config := &packet.Config{Time: clock.Now} //nolint:exhaustruct
+	_, err = openpgp.CheckArmoredDetachedSignature(
+		openpgp.EntityList{entity},
+		strings.NewReader(signedKeyList),
+		strings.NewReader(signature),
+		config,
+	)
+	if err != nil {
+		return fmt.Errorf("ktclient: %w: %v", errSignedKeyListSignature, err) //nolint:errorlint
+	}
+
+	return nil
+}
+
+// VerifySignedKeyList checks that the signed key list is signed by the
+// primary key of the address, see VerifySignedKeyListSignature, and that it
+// is inserted in the tree of rootHashHex, see VerifyInsertionProof:
+// absence proofs are rejected.
+// The epoch of rootHashHex must be verified separately.
+func VerifySignedKeyList(
+	email string,
+	revision int,
+	signedKeyList string,
+	signature string,
+	primaryKey string,
+	minEpochID int,
+	vrfPublicKeyBase64 string,
+	rootHashHex string,
+	proof *InsertionProof,
+	currentUnixTime int64,
+) error {
+	if err := VerifySignedKeyListSignature(signedKeyList, signature, primaryKey, currentUnixTime); err != nil {
+		return err
+	}
+	if proof.ProofType == absenceProofType {
+		return errors.Wrap(errMerkleProof, "ktclient: absence proof for a signed key list")
+	}
+
+	return VerifyInsertionProof(email, revision, signedKeyList, minEpochID, vrfPublicKeyBase64, rootHashHex, proof)
+}
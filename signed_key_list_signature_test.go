@@ -0,0 +1,120 @@
+package ktclient
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/stretchr/testify/assert"
+)
+
+// testSignedSKL is a signed key list of a generated address key.
+type testSignedSKL struct {
+	entity    *openpgp.Entity
+	data      string
+	signature string
+	publicKey string
+}
+
+func newTestSignedSKL(t *testing.T) *testSignedSKL {
+	t.Helper()
+	entity := newTestEntity(t)
+	data, err := json.Marshal(SignedKeyList{
+		testSignedKeyListEntry(t, entity, 1, KeyFlagNotCompromised|KeyFlagNotObsolete),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	skl := &testSignedSKL{entity: entity, data: string(data), publicKey: armorTestPublicKey(t, entity)}
+	skl.signature = signTestSKL(t, entity, skl.data)
+
+	return skl
+}
+
+func signTestSKL(t *testing.T, entity *openpgp.Entity, data string) string {
+	t.Helper()
+	var signature strings.Builder
+	err := openpgp.ArmoredDetachSign(&signature, entity, strings.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signature.String()
+}
+
+// testSKLProof returns the proof and root hash of a tree holding the signed key list only.
+func testSKLProof(t *testing.T, signedKeyList string, revision, minEpochID int) (*InsertionProof, string) {
+	t.Helper()
+	vrfHash, err := verifyVRFOutput(testSKLEmail, testSKLVRFProof, testVRFPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof := &InsertionProof{ProofType: presenceProofType, VRFProofHex: testSKLVRFProof} //nolint:exhaustruct
+	leaf, err := computeLeafNode(proof, SHA256HashSuite(), TreeVersion1, minEpochID, signedKeyList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootHash, err := computeRootHash(TreeVersion1.treePath(vrfHash, revision), proof, leaf, SHA256HashSuite())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return proof, hex.EncodeToString(rootHash)
+}
+
+func TestVerifySignedKeyListSignature(t *testing.T) {
+	t.Parallel()
+	skl := newTestSignedSKL(t)
+	assert.NoError(t, VerifySignedKeyListSignature(skl.data, skl.signature, skl.publicKey, 0))
+
+	other := newTestSignedSKL(t)
+	err := VerifySignedKeyListSignature(skl.data, skl.signature, other.publicKey, 0)
+	assert.True(t, errors.Is(err, errSignedKeyListSignature), err)
+	// A list signed by another key than its primary key.
+	err = VerifySignedKeyListSignature(skl.data, signTestSKL(t, other.entity, skl.data), skl.publicKey, 0)
+	assert.True(t, errors.Is(err, errSignedKeyListSignature), err)
+	// A signature of other data.
+	err = VerifySignedKeyListSignature(skl.data, other.signature, skl.publicKey, 0)
+	assert.True(t, errors.Is(err, errSignedKeyListSignature), err)
+	// A signature made after the current time.
+	past := time.Now().Add(-time.Hour).Unix()
+	err = VerifySignedKeyListSignature(skl.data, skl.signature, skl.publicKey, past)
+	assert.True(t, errors.Is(err, errSignedKeyListSignature), err)
+
+	err = VerifySignedKeyListSignature(skl.data, "not a signature", skl.publicKey, 0)
+	assert.True(t, errors.Is(err, errSignedKeyListSignature), err)
+	err = VerifySignedKeyListSignature(skl.data, skl.signature, "not a key", 0)
+	assert.True(t, errors.Is(err, errOpenPGPKey), err)
+	err = VerifySignedKeyListSignature("[]", skl.signature, skl.publicKey, 0)
+	assert.True(t, errors.Is(err, errSignedKeyList), err)
+}
+
+func TestVerifySignedKeyList(t *testing.T) {
+	t.Parallel()
+	skl := newTestSignedSKL(t)
+	proof, rootHash := testSKLProof(t, skl.data, 1, 10)
+	assert.NoError(t, VerifySignedKeyList(
+		testSKLEmail, 1, skl.data, skl.signature, skl.publicKey, 10, testVRFPublicKey, rootHash, proof, 0,
+	))
+
+	err := VerifySignedKeyList(
+		testSKLEmail, 2, skl.data, skl.signature, skl.publicKey, 10, testVRFPublicKey, rootHash, proof, 0,
+	)
+	assert.True(t, errors.Is(err, errIntegrity), err)
+
+	other := newTestSignedSKL(t)
+	err = VerifySignedKeyList(
+		testSKLEmail, 1, skl.data, skl.signature, other.publicKey, 10, testVRFPublicKey, rootHash, proof, 0,
+	)
+	assert.True(t, errors.Is(err, errSignedKeyListSignature), err)
+
+	absenceProof := &InsertionProof{ProofType: absenceProofType, VRFProofHex: testSKLVRFProof} //nolint:exhaustruct
+	err = VerifySignedKeyList(
+		testSKLEmail, 1, skl.data, skl.signature, skl.publicKey, 10, testVRFPublicKey, rootHash, absenceProof, 0,
+	)
+	assert.True(t, errors.Is(err, errMerkleProof), err)
+}
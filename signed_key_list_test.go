@@ -0,0 +1,160 @@
+package ktclient
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/stretchr/testify/assert"
+)
+
+func readTestPublicKey(t *testing.T) string {
+	t.Helper()
+	armoredKey, err := os.ReadFile("testdata/skl_public_key.asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(armoredKey)
+}
+
+// newTestEntity generates an Ed25519 OpenPGP key.
+func newTestEntity(t *testing.T) *openpgp.Entity {
+	t.Helper()
+	entity, err := openpgp.NewEntity("", "", "alice@proton.me", &packet.Config{ //nolint:exhaustruct
+		Algorithm: packet.PubKeyAlgoEdDSA,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return entity
+}
+
+func armorTestPublicKey(t *testing.T, entity *openpgp.Entity) string {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer, err := armor.Encode(&buffer, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(writer); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.String()
+}
+
+func testSignedKeyListEntry(t *testing.T, entity *openpgp.Entity, primary, flags int) SignedKeyListEntry {
+	t.Helper()
+	fingerprints, err := sha256Fingerprints(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return SignedKeyListEntry{
+		Primary:            primary,
+		Flags:              flags,
+		Fingerprint:        hex.EncodeToString(entity.PrimaryKey.Fingerprint),
+		SHA256Fingerprints: fingerprints,
+	}
+}
+
+func TestParseSignedKeyList(t *testing.T) {
+	t.Parallel()
+	list, err := ParseSignedKeyList(testSignedKeyList)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "43eb8f7cc59576c0bca4414258518450b9119b5d", list.PrimaryKey().Fingerprint)
+	assert.Equal(t, KeyFlagNotCompromised|KeyFlagNotObsolete, list.PrimaryKey().Flags)
+	assert.Len(t, list.PrimaryKey().SHA256Fingerprints, 2)
+
+	const fingerprint = `"Fingerprint":"43eb8f7cc59576c0bca4414258518450b9119b5d"`
+	const sha256Fingerprints = `"SHA256Fingerprints":["357f701a502e62192022d363af687d52308352ef8ac53a8bd139fa2b9dd3c6a9"]`
+	for name, data := range map[string]string{
+		"not a list":       `{}`,
+		"empty":            `[]`,
+		"trailing data":    testSignedKeyList + `[]`,
+		"unknown field":    `[{"Primary":1,"Flags":3,` + fingerprint + `,` + sha256Fingerprints + `,"Extra":1}]`,
+		"missing field":    `[{"Primary":1,"Flags":3,` + fingerprint + `}]`,
+		"no primary":       `[{"Primary":0,"Flags":3,` + fingerprint + `,` + sha256Fingerprints + `}]`,
+		"invalid primary":  `[{"Primary":2,"Flags":3,` + fingerprint + `,` + sha256Fingerprints + `}]`,
+		"unknown flags":    `[{"Primary":1,"Flags":19,` + fingerprint + `,` + sha256Fingerprints + `}]`,
+		"obsolete primary": `[{"Primary":1,"Flags":1,` + fingerprint + `,` + sha256Fingerprints + `}]`,
+		"compromised key":  `[{"Primary":1,"Flags":2,` + fingerprint + `,` + sha256Fingerprints + `}]`,
+		"short fingerprint": `[{"Primary":1,"Flags":3,"Fingerprint":"43eb8f7c",` +
+			sha256Fingerprints + `}]`,
+		"uppercase fingerprint": `[{"Primary":1,"Flags":3,"Fingerprint":"43EB8F7CC59576C0BCA4414258518450B9119B5D",` +
+			sha256Fingerprints + `}]`,
+		"no SHA-256 fingerprint": `[{"Primary":1,"Flags":3,` + fingerprint + `,"SHA256Fingerprints":[]}]`,
+		"duplicate key": `[{"Primary":1,"Flags":3,` + fingerprint + `,` + sha256Fingerprints + `},` +
+			`{"Primary":0,"Flags":3,` + fingerprint + `,` + sha256Fingerprints + `}]`,
+	} {
+		_, err := ParseSignedKeyList(data)
+		assert.True(t, errors.Is(err, errSignedKeyList), "%s: %v", name, err)
+	}
+}
+
+func TestSHA256Fingerprints(t *testing.T) {
+	t.Parallel()
+	entity, err := readPublicKey(readTestPublicKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprints, err := sha256Fingerprints(entity)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"d9ac0b857da6d2c8be985b251a9e3db31e7a1d2d832d1f07ebe838a9edce9c24",
+		"203dfba1f8442c17e59214d9cd11985bfc5cc8721bb4a71740dd5507e58a1a0d",
+	}, fingerprints)
+}
+
+func TestMatchKeys(t *testing.T) {
+	t.Parallel()
+	primary, secondary := newTestEntity(t), newTestEntity(t)
+	list := SignedKeyList{
+		testSignedKeyListEntry(t, primary, 1, KeyFlagNotCompromised|KeyFlagNotObsolete),
+		testSignedKeyListEntry(t, secondary, 0, KeyFlagNotCompromised),
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err = ParseSignedKeyList(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []AddressKey{
+		{PublicKey: armorTestPublicKey(t, secondary), Primary: false, Flags: KeyFlagNotCompromised},
+		{PublicKey: armorTestPublicKey(t, primary), Primary: true, Flags: KeyFlagNotCompromised | KeyFlagNotObsolete},
+	}
+	assert.NoError(t, list.MatchKeys(keys))
+
+	for name, keys := range map[string][]AddressKey{
+		"missing key": keys[:1],
+		"other key": {
+			keys[0],
+			{PublicKey: readTestPublicKey(t), Primary: true, Flags: KeyFlagNotCompromised | KeyFlagNotObsolete},
+		},
+		"duplicate key":  {keys[1], keys[1]},
+		"primary status": {{keys[0].PublicKey, true, keys[0].Flags}, {keys[1].PublicKey, false, keys[1].Flags}},
+		"flags":          {{keys[0].PublicKey, false, KeyFlagNotCompromised | KeyFlagNotObsolete}, keys[1]},
+	} {
+		assert.True(t, errors.Is(list.MatchKeys(keys), errKeyMismatch), name)
+	}
+
+	// A subkey missing from the list.
+	list[1].SHA256Fingerprints = list[1].SHA256Fingerprints[:1]
+	assert.True(t, errors.Is(list.MatchKeys(keys), errKeyMismatch))
+
+	assert.True(t, errors.Is(list.MatchKeys([]AddressKey{{"not a key", true, 3}, keys[0]}), errOpenPGPKey))
+}
@@ -0,0 +1,240 @@
+package ktclient
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/pkg/errors"
+)
+
+// ctLogBatchSize is the number of entries requested at once from a CT log.
+const ctLogBatchSize = 256
+
+// SplitViewAlert reports that conflicting claims were seen for the same
+// epoch: different chain hashes or certificate times for one epoch ID.
+// This is evidence that the server shows different trees to different users.
+type SplitViewAlert struct {
+	EpochID    int
+	BaseDomain string
+	Claims     []EpochClaim
+}
+
+// InvalidEpochCertificatesError reports the entries of a CT log which claim
+// an epoch of a monitored base domain, but whose certificate does not verify.
+// They may be mis-issued certificates for the deployment.
+type InvalidEpochCertificatesError struct {
+	LogName string
+	// Errors holds the verification error of each entry, by index.
+	Errors map[int64]error
+}
+
+func (e *InvalidEpochCertificatesError) Error() string {
+	indices := make([]int64, 0, len(e.Errors))
+	for index := range e.Errors {
+		indices = append(indices, index)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+
+	return fmt.Sprintf(
+		"ktclient: %v: invalid epoch certificates in CT log %s at %v: %v",
+		errCert, e.LogName, indices, e.Errors[indices[0]],
+	)
+}
+
+func (e *InvalidEpochCertificatesError) Unwrap() error {
+	return errCert
+}
+
+type epochClaimKey struct {
+	epochID    int
+	baseDomain string
+}
+
+// EpochMonitor watches the epoch certificates logged in CT logs and the
+// epochs served to the client, and raises a SplitViewAlert when two of them
+// claim different contents for the same epoch.
+type EpochMonitor struct {
+	mutex             sync.Mutex
+	claims            map[epochClaimKey][]EpochClaim
+	alerts            []*SplitViewAlert
+	cursors           map[string]int64
+	baseDomains       map[string]bool
+	verifyCertificate func(certificateChain string) (*x509.Certificate, error)
+}
+
+// NewEpochMonitor creates an EpochMonitor that has not seen any epoch.
+// Poll reports the logged certificates claiming an epoch of one of the
+// base domains which do not verify.
+func NewEpochMonitor(baseDomains ...string) *EpochMonitor {
+	monitor := &EpochMonitor{ //nolint:exhaustruct
+		claims:            make(map[epochClaimKey][]EpochClaim),
+		cursors:           make(map[string]int64),
+		baseDomains:       make(map[string]bool, len(baseDomains)),
+		verifyCertificate: verifyLoggedEpochCertificate,
+	}
+	for _, baseDomain := range baseDomains {
+		monitor.baseDomains[baseDomain] = true
+	}
+
+	return monitor
+}
+
+// IngestCertificate records the epoch claimed by a logged epoch certificate.
+// The chain must lead to one of the known issuers, and its SCTs must verify.
+// It returns the alert raised by the certificate, or nil.
+func (m *EpochMonitor) IngestCertificate(certificateChain string) (*SplitViewAlert, error) {
+	cert, err := m.verifyCertificate(certificateChain)
+	if err != nil {
+		return nil, err
+	}
+	claim, err := epochClaimFromX509(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.record(claim), nil
+}
+
+// ObserveEpoch verifies an epoch served to the client with
+// VerifyEpochWithOptions, and records the epoch it claims.
+// It returns the alert raised by the epoch, or nil.
+func (m *EpochMonitor) ObserveEpoch(
+	epoch *Epoch,
+	baseDomain string,
+	currentUnixTime int64,
+	options *EpochOptions,
+) (*SplitViewAlert, error) {
+	if _, err := VerifyEpochWithOptions(epoch, baseDomain, currentUnixTime, options); err != nil {
+		return nil, err
+	}
+	if options == nil {
+		options = &EpochOptions{} //nolint:exhaustruct
+	}
+	treeVersion, err := resolveTreeVersion(options.TreeVersion)
+	if err != nil {
+		return nil, err
+	}
+	// Claims from certificates have lowercase chain hashes.
+	chainHash, err := decodeHex(epoch.ChainHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "ktclient: invalid encoding of chain hash")
+	}
+
+	return m.record(&EpochClaim{
+		ChainHash:       hex.EncodeToString(chainHash),
+		CertificateTime: epoch.CertificateTime,
+		EpochID:         epoch.EpochID,
+		NameVersion:     treeVersion.nameVersion(),
+		BaseDomain:      baseDomain,
+	}), nil
+}
+
+// Poll ingests the entries added to the CT log since the last poll of the
+// log with the same name. It returns the alerts raised by the new entries.
+// Entries that are not epoch certificates of a monitored base domain are
+// skipped if they do not verify; those of a monitored base domain are
+// reported in an *InvalidEpochCertificatesError, once all entries are ingested.
+func (m *EpochMonitor) Poll(logName string, source CTLogEntrySource) ([]*SplitViewAlert, error) {
+	var alerts []*SplitViewAlert
+	invalid := &InvalidEpochCertificatesError{LogName: logName, Errors: make(map[int64]error)}
+	for {
+		m.mutex.Lock()
+		start := m.cursors[logName]
+		m.mutex.Unlock()
+		entries, err := source.GetEntries(start, ctLogBatchSize)
+		if err != nil {
+			return alerts, err //nolint:wrapcheck
+		}
+		if len(entries) == 0 {
+			if len(invalid.Errors) > 0 {
+				return alerts, invalid
+			}
+
+			return alerts, nil
+		}
+		for _, entry := range entries {
+			alert, err := m.IngestCertificate(entry.CertificateChain)
+			switch {
+			case err != nil && m.claimsMonitoredDomain(entry.CertificateChain):
+				invalid.Errors[entry.Index] = err
+			case alert != nil:
+				alerts = append(alerts, alert)
+			}
+		}
+		m.mutex.Lock()
+		m.cursors[logName] = start + int64(len(entries))
+		m.mutex.Unlock()
+	}
+}
+
+// claimsMonitoredDomain tells whether the certificate has the alternate name
+// of an epoch of a monitored base domain, without verifying it.
+func (m *EpochMonitor) claimsMonitoredDomain(certificateChain string) bool {
+	cert, _, err := convertPEMEncodedCertToX509Cert([]byte(certificateChain))
+	if err != nil {
+		return false
+	}
+	for _, altName := range cert.DNSNames {
+		if claim, ok := parseEpochName(altName); ok && m.baseDomains[claim.BaseDomain] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Alerts returns all the alerts raised so far.
+func (m *EpochMonitor) Alerts() []*SplitViewAlert {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([]*SplitViewAlert(nil), m.alerts...)
+}
+
+func (m *EpochMonitor) record(claim *EpochClaim) *SplitViewAlert {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	key := epochClaimKey{epochID: claim.EpochID, baseDomain: claim.BaseDomain}
+	claims := m.claims[key]
+	for _, seen := range claims {
+		if seen.ChainHash == claim.ChainHash && seen.CertificateTime == claim.CertificateTime {
+			return nil
+		}
+	}
+	claims = append(claims, *claim)
+	m.claims[key] = claims
+	if len(claims) < 2 {
+		return nil
+	}
+	alert := &SplitViewAlert{
+		EpochID:    claim.EpochID,
+		BaseDomain: claim.BaseDomain,
+		Claims:     append([]EpochClaim(nil), claims...),
+	}
+	m.alerts = append(m.alerts, alert)
+
+	return alert
+}
+
+// verifyLoggedEpochCertificate verifies a certificate chain read from a CT log,
+// whose issuer is not known in advance. Logged certificates may have expired,
+// so the chain is verified at the time the certificate was issued.
+func verifyLoggedEpochCertificate(certificateChain string) (*x509.Certificate, error) {
+	cert, _, err := convertPEMEncodedCertToX509Cert([]byte(certificateChain))
+	if err != nil {
+		return nil, err
+	}
+	issuanceTime := cert.NotBefore.Add(time.Second)
+	for _, issuer := range []int{letsEncryptIssuer, zeroSSLIssuer} {
+		cert, err = verifyEpochCertificate(certificateChain, issuer, issuanceTime, nil)
+		if err == nil {
+			return cert, nil
+		}
+	}
+
+	return nil, err
+}
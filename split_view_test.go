@@ -0,0 +1,166 @@
+package ktclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	stdx509 "crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestEpochCertificate returns a PEM encoded self-signed certificate
+// with the given alternate names.
+func newTestEpochCertificate(t *testing.T, dnsNames ...string) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &stdx509.Certificate{ //nolint:exhaustruct
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: dnsNames[0]}, //nolint:exhaustruct
+		NotBefore:    time.Unix(1_689_033_600, 0),
+		NotAfter:     time.Unix(1_696_895_999, 0),
+		DNSNames:     dnsNames,
+	}
+	der, err := stdx509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})) //nolint:exhaustruct
+}
+
+func newUnverifiedEpochMonitor() *EpochMonitor {
+	monitor := NewEpochMonitor()
+	monitor.verifyCertificate = func(certificateChain string) (*x509.Certificate, error) {
+		cert, _, err := convertPEMEncodedCertToX509Cert([]byte(certificateChain))
+
+		return cert, err
+	}
+
+	return monitor
+}
+
+const (
+	testEpochName         = "506062a81b4f2ae8aeb2f6dd2d003ada.c3cd1e84a39d19225a8653d1012cf0d2.1689062740.46.1.dev.proton.wtf"
+	testForkedEpochName   = "00000000000000000000000000000000.c3cd1e84a39d19225a8653d1012cf0d2.1689062740.46.1.dev.proton.wtf"
+	testRetimedEpochName  = "506062a81b4f2ae8aeb2f6dd2d003ada.c3cd1e84a39d19225a8653d1012cf0d2.1689062999.46.1.dev.proton.wtf"
+	testOtherDomainName   = "00000000000000000000000000000000.c3cd1e84a39d19225a8653d1012cf0d2.1689062740.46.1.proton.me"
+	testNextEpochName     = "00000000000000000000000000000000.c3cd1e84a39d19225a8653d1012cf0d2.1689062740.47.1.dev.proton.wtf"
+	testEpochSubjectName  = "epoch.46.1.dev.proton.wtf"
+	testUnrelatedCertName = "www.proton.me"
+)
+
+func TestEpochMonitorVerifiesLoggedCertificates(t *testing.T) {
+	t.Parallel()
+	log := newMemoryCTLog(t)
+	_, err := log.AddChain(certificateChain)
+	assert.NoError(t, err)
+	_, err = log.AddChain(newTestEpochCertificate(t, testEpochSubjectName, testForkedEpochName))
+	assert.NoError(t, err)
+	for _, name := range []string{testUnrelatedCertName, testOtherDomainName} {
+		_, err = log.AddChain(newTestEpochCertificate(t, name))
+		assert.NoError(t, err)
+	}
+
+	monitor := NewEpochMonitor("dev.proton.wtf")
+	alerts, err := monitor.Poll("log", log)
+	var invalid *InvalidEpochCertificatesError
+	if assert.True(t, errors.As(err, &invalid), err) {
+		assert.Equal(t, "log", invalid.LogName)
+		assert.Len(t, invalid.Errors, 1, "only the epoch certificates of the base domain are reported")
+		assert.Error(t, invalid.Errors[1])
+	}
+	assert.True(t, errors.Is(err, errCert), err)
+	assert.Empty(t, alerts, "self-signed certificates must not be recorded")
+	assert.Len(t, monitor.claims, 1)
+	alerts, err = monitor.Poll("log", log)
+	assert.NoError(t, err, "invalid entries are reported once")
+	assert.Empty(t, alerts)
+
+	_, err = monitor.IngestCertificate(newTestEpochCertificate(t, testForkedEpochName))
+	assert.Error(t, err)
+}
+
+func TestEpochMonitorDetectsSplitView(t *testing.T) {
+	t.Parallel()
+	log := newMemoryCTLog(t)
+	for _, names := range [][]string{
+		{testEpochSubjectName, testEpochName},
+		{testUnrelatedCertName},
+		{testEpochSubjectName, testEpochName},
+		{testOtherDomainName},
+		{testNextEpochName},
+	} {
+		_, err := log.AddChain(newTestEpochCertificate(t, names...))
+		assert.NoError(t, err)
+	}
+	monitor := newUnverifiedEpochMonitor()
+	alerts, err := monitor.Poll("log", log)
+	assert.NoError(t, err)
+	assert.Empty(t, alerts)
+
+	_, err = log.AddChain(newTestEpochCertificate(t, testForkedEpochName))
+	assert.NoError(t, err)
+	alerts, err = monitor.Poll("log", log)
+	assert.NoError(t, err)
+	assert.Len(t, alerts, 1)
+	assert.Equal(t, 46, alerts[0].EpochID)
+	assert.Equal(t, "dev.proton.wtf", alerts[0].BaseDomain)
+	assert.Len(t, alerts[0].Claims, 2)
+
+	alerts, err = monitor.Poll("log", log)
+	assert.NoError(t, err)
+	assert.Empty(t, alerts, "entries must not be ingested twice")
+
+	alert, err := monitor.IngestCertificate(newTestEpochCertificate(t, testRetimedEpochName))
+	assert.NoError(t, err)
+	assert.NotNil(t, alert)
+	assert.Len(t, alert.Claims, 3)
+	assert.Len(t, monitor.Alerts(), 2)
+}
+
+func TestEpochMonitorComparesServedEpochs(t *testing.T) {
+	t.Parallel()
+	epoch := Epoch{
+		EpochID:           46,
+		PreviousChainHash: "9624e880fe4b49b45fc15ca7e16b7ed8a846724a1802b2a2ca9d749770b00f4b",
+		CertificateChain:  certificateChain,
+		CertificateIssuer: 1,
+		TreeHash:          "65e8dd7b133f7a02fe0e249928f7fdf1a21df8a5923234c97e0177f0f56a194c",
+		ChainHash:         "506062a81b4f2ae8aeb2f6dd2d003adac3cd1e84a39d19225a8653d1012cf0d2",
+		CertificateTime:   1_689_062_740,
+	}
+	monitor := newUnverifiedEpochMonitor()
+	alert, err := monitor.ObserveEpoch(&epoch, "dev.proton.wtf", 1_689_062_740, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, alert)
+	alert, err = monitor.IngestCertificate(certificateChain)
+	assert.NoError(t, err)
+	assert.Nil(t, alert)
+	alert, err = monitor.IngestCertificate(newTestEpochCertificate(t, testForkedEpochName))
+	assert.NoError(t, err)
+	assert.NotNil(t, alert)
+
+	// The same epoch served with uppercase hex.
+	upperEpoch := epoch
+	upperEpoch.ChainHash = strings.ToUpper(epoch.ChainHash)
+	alert, err = monitor.ObserveEpoch(&upperEpoch, "dev.proton.wtf", 1_689_062_740, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, alert)
+
+	badEpoch := epoch
+	badEpoch.TreeHash = epoch.PreviousChainHash
+	_, err = monitor.ObserveEpoch(&badEpoch, "dev.proton.wtf", 1_689_062_740, nil)
+	assert.Error(t, err)
+}
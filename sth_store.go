@@ -0,0 +1,209 @@
+package ktclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/rfc6962"
+	"github.com/pkg/errors"
+)
+
+// sthStoreStorageKey is the Storage key under which an STHStore is saved.
+const sthStoreStorageKey = "ktclient/sth-store"
+
+// CTLogConsistencySource fetches signed tree heads and consistency proofs
+// from a Certificate Transparency log, see RFC 6962 section 4.
+type CTLogConsistencySource interface {
+	// GetSTH returns the latest signed tree head of the log.
+	GetSTH() (*ct.SignedTreeHead, error)
+	// GetSTHConsistency returns the consistency proof between
+	// the trees of the two sizes.
+	GetSTHConsistency(first, second uint64) ([][]byte, error)
+}
+
+// STHStore keeps the latest signed tree head seen for each CT log, and
+// checks that every new tree head is consistent with it. A log showing
+// inconsistent tree heads has forked. The tree heads can be exported and
+// imported, so that clients can compare the views of the logs they got.
+type STHStore struct {
+	mutex      sync.Mutex
+	publicKeys map[string]ctPublicKey
+	sths       map[string]*ct.SignedTreeHead
+}
+
+// sthJSON is the serialized form of an STH of an STHStore.
+type sthJSON struct {
+	LogID string
+	STH   *ct.SignedTreeHead
+}
+
+// NewSTHStore creates an empty STHStore for the logs of the CT log list.
+func NewSTHStore() (*STHStore, error) {
+	publicKeys, err := parseCTPublicKeys(ctLogs)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSTHStoreWithKeys(publicKeys), nil
+}
+
+func newSTHStoreWithKeys(publicKeys map[string]ctPublicKey) *STHStore {
+	return &STHStore{ //nolint:exhaustruct
+		publicKeys: publicKeys,
+		sths:       make(map[string]*ct.SignedTreeHead),
+	}
+}
+
+// Update fetches the latest tree head of the log and adds it to the store.
+func (s *STHStore) Update(logID string, source CTLogConsistencySource) error {
+	sth, err := source.GetSTH()
+	if err != nil {
+		return errors.Wrap(err, "ktclient: get STH")
+	}
+
+	return s.AddSTH(logID, sth, source)
+}
+
+// AddSTH verifies the signature of a tree head of the log, and its
+// consistency with the stored tree head, fetching a consistency proof from
+// source if needed. The store keeps the largest of the two tree heads.
+func (s *STHStore) AddSTH(logID string, sth *ct.SignedTreeHead, source CTLogConsistencySource) error {
+	key, ok := s.publicKeys[logID]
+	if !ok {
+		return fmt.Errorf("ktclient: %w: no public key available for log ID %s", errCTLog, logID)
+	}
+	if err := verifySTHSignature(sth, key); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for {
+		stored := s.sths[logID]
+		if stored != nil {
+			older, newer := stored, sth
+			if sth.TreeSize < stored.TreeSize {
+				older, newer = sth, stored
+			}
+			// The consistency proof is fetched without holding the lock.
+			s.mutex.Unlock()
+			err := verifySTHConsistency(older, newer, source)
+			s.mutex.Lock()
+			if err != nil {
+				return errors.Wrap(err, fmt.Sprintf("ktclient: log ID %s", logID))
+			}
+		}
+		// Check again if another tree head was stored in the meantime.
+		if s.sths[logID] != stored {
+			continue
+		}
+		if stored == nil || sth.TreeSize > stored.TreeSize {
+			s.sths[logID] = sth
+		}
+
+		return nil
+	}
+}
+
+// LatestSTH returns the largest tree head stored for the log, or nil.
+func (s *STHStore) LatestSTH(logID string) *ct.SignedTreeHead {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.sths[logID]
+}
+
+// Export encodes the stored tree heads in JSON, to share them with other clients.
+func (s *STHStore) Export() ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sths := make([]sthJSON, 0, len(s.sths))
+	for logID, sth := range s.sths {
+		sths = append(sths, sthJSON{LogID: logID, STH: sth})
+	}
+	sort.Slice(sths, func(i, j int) bool { return sths[i].LogID < sths[j].LogID })
+	data, err := json.Marshal(sths)
+	if err != nil {
+		return nil, errors.Wrap(err, "ktclient: encode STHs")
+	}
+
+	return data, nil
+}
+
+// Import adds the tree heads exported by another store, as with AddSTH.
+// Consistency proofs are fetched from sources, indexed by log ID.
+// Tree heads of logs unknown to this store are ignored.
+func (s *STHStore) Import(data []byte, sources map[string]CTLogConsistencySource) error {
+	var sths []sthJSON
+	if err := json.Unmarshal(data, &sths); err != nil {
+		return errors.Wrap(err, "ktclient: invalid STH encoding")
+	}
+	for _, entry := range sths {
+		if _, ok := s.publicKeys[entry.LogID]; !ok {
+			continue
+		}
+		if entry.STH == nil {
+			return fmt.Errorf("ktclient: %w: missing STH for log ID %s", errCTLog, entry.LogID)
+		}
+		if err := s.AddSTH(entry.LogID, entry.STH, sources[entry.LogID]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Save persists the stored tree heads to the storage.
+func (s *STHStore) Save(storage Storage) error {
+	data, err := s.Export()
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(storage.Set(sthStoreStorageKey, data), "ktclient: save STHs")
+}
+
+// Load adds the tree heads saved to the storage. As they were verified
+// when first added, no consistency proof is needed for an empty store.
+func (s *STHStore) Load(storage Storage) error {
+	data, err := storage.Get(sthStoreStorageKey)
+	if err != nil {
+		return errors.Wrap(err, "ktclient: load STHs")
+	}
+	if data == nil {
+		return nil
+	}
+
+	return s.Import(data, nil)
+}
+
+// verifySTHConsistency checks that the newer tree head extends the older
+// one, fetching a consistency proof from source unless both are the same size.
+func verifySTHConsistency(older, newer *ct.SignedTreeHead, source CTLogConsistencySource) error {
+	var proof [][]byte
+	if older.TreeSize != newer.TreeSize && older.TreeSize > 0 {
+		if source == nil {
+			return fmt.Errorf("ktclient: %w: no log to fetch a consistency proof from", errCTLog)
+		}
+		var err error
+		proof, err = source.GetSTHConsistency(older.TreeSize, newer.TreeSize)
+		if err != nil {
+			return errors.Wrap(err, "ktclient: get consistency proof")
+		}
+	}
+	err := merkle.NewLogVerifier(rfc6962.DefaultHasher).VerifyConsistencyProof(
+		int64(older.TreeSize),
+		int64(newer.TreeSize),
+		older.SHA256RootHash[:],
+		newer.SHA256RootHash[:],
+		proof,
+	)
+	if err != nil {
+		return fmt.Errorf("ktclient: %w: inconsistent tree heads: %v", errSTHConsistency, err) //nolint:errorlint
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package ktclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func addTestEntries(t *testing.T, log *memoryCTLog, count int, name string) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		_, err := log.AddChain(newTestEpochCertificate(t, name))
+		assert.NoError(t, err)
+	}
+}
+
+func newTestSTHStore(logs ...*memoryCTLog) *STHStore {
+	publicKeys := make(map[string]ctPublicKey)
+	for _, log := range logs {
+		publicKeys[log.LogID()] = ctPublicKey{OperatorName: "test", PublicKey: log.PublicKey()}
+	}
+
+	return newSTHStoreWithKeys(publicKeys)
+}
+
+func TestSTHStoreConsistentUpdates(t *testing.T) {
+	t.Parallel()
+	log := newMemoryCTLog(t)
+	store := newTestSTHStore(log)
+	assert.NoError(t, store.Update(log.LogID(), log))
+	for _, count := range []int{1, 2, 5, 8} {
+		addTestEntries(t, log, count, testUnrelatedCertName)
+		assert.NoError(t, store.Update(log.LogID(), log))
+	}
+	assert.Equal(t, uint64(16), store.LatestSTH(log.LogID()).TreeSize)
+
+	older, err := log.GetSTH()
+	assert.NoError(t, err)
+	addTestEntries(t, log, 3, testUnrelatedCertName)
+	assert.NoError(t, store.Update(log.LogID(), log))
+	assert.NoError(t, store.AddSTH(log.LogID(), older, log), "older consistent STHs are accepted")
+	assert.Equal(t, uint64(19), store.LatestSTH(log.LogID()).TreeSize)
+}
+
+func TestSTHStoreDetectsForks(t *testing.T) {
+	t.Parallel()
+	log := newMemoryCTLog(t)
+	fork := newForkedCTLog(t, log)
+	addTestEntries(t, log, 4, testUnrelatedCertName)
+	addTestEntries(t, fork, 4, testEpochSubjectName)
+	store := newTestSTHStore(log)
+	assert.NoError(t, store.Update(log.LogID(), log))
+	assert.True(t, errors.Is(store.Update(log.LogID(), fork), errSTHConsistency), "same size")
+
+	addTestEntries(t, fork, 3, testEpochSubjectName)
+	assert.True(t, errors.Is(store.Update(log.LogID(), fork), errSTHConsistency), "larger fork")
+	assert.Equal(t, uint64(4), store.LatestSTH(log.LogID()).TreeSize)
+
+	otherLog := newMemoryCTLog(t)
+	assert.True(t, errors.Is(store.Update(otherLog.LogID(), otherLog), errCTLog), "unknown log")
+	impostor := newMemoryCTLog(t)
+	impostor.logID = log.logID
+	assert.True(t, errors.Is(store.Update(log.LogID(), impostor), errCTLog), "bad signature")
+}
+
+// blockingConsistencySource blocks the consistency proofs of a log until
+// release is closed, signaling on started when a proof is requested.
+type blockingConsistencySource struct {
+	*memoryCTLog
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingConsistencySource) GetSTHConsistency(first, second uint64) ([][]byte, error) {
+	s.started <- struct{}{}
+	<-s.release
+
+	return s.memoryCTLog.GetSTHConsistency(first, second)
+}
+
+func TestSTHStoreConcurrentForks(t *testing.T) {
+	t.Parallel()
+	// The test certificate has an SCT of the log, so both views log it
+	// with the same timestamp.
+	logs, _ := newImpersonatingCTLogs(t)
+	var log *memoryCTLog
+	for _, log = range logs {
+		break
+	}
+	fork := newForkedCTLog(t, log)
+	for _, view := range []*memoryCTLog{log, fork} {
+		_, err := view.AddChain(certificateChain)
+		assert.NoError(t, err)
+	}
+	store := newTestSTHStore(log)
+	assert.NoError(t, store.Update(log.LogID(), log))
+
+	// Two views of the log, of the same size, both consistent with the stored one.
+	addTestEntries(t, log, 3, testUnrelatedCertName)
+	addTestEntries(t, fork, 3, testEpochSubjectName)
+	sth, err := log.GetSTH()
+	assert.NoError(t, err)
+	forkedSTH, err := fork.GetSTH()
+	assert.NoError(t, err)
+
+	source := &blockingConsistencySource{
+		memoryCTLog: log,
+		started:     make(chan struct{}, 1),
+		release:     make(chan struct{}),
+	}
+	done := make(chan error)
+	go func() { done <- store.AddSTH(log.LogID(), sth, source) }()
+	<-source.started
+	assert.NoError(t, store.AddSTH(log.LogID(), forkedSTH, fork))
+	close(source.release)
+	assert.True(t, errors.Is(<-done, errSTHConsistency))
+	assert.Equal(t, forkedSTH, store.LatestSTH(log.LogID()))
+}
+
+func TestSTHStoreGossip(t *testing.T) {
+	t.Parallel()
+	log := newMemoryCTLog(t)
+	fork := newForkedCTLog(t, log)
+	addTestEntries(t, log, 5, testUnrelatedCertName)
+	addTestEntries(t, fork, 5, testEpochSubjectName)
+
+	alice, bob, carol := newTestSTHStore(log), newTestSTHStore(log), newTestSTHStore(log)
+	assert.NoError(t, alice.Update(log.LogID(), log))
+	addTestEntries(t, log, 2, testUnrelatedCertName)
+	assert.NoError(t, bob.Update(log.LogID(), log))
+	assert.NoError(t, carol.Update(log.LogID(), fork))
+
+	exported, err := alice.Export()
+	assert.NoError(t, err)
+	sources := map[string]CTLogConsistencySource{log.LogID(): log}
+	assert.NoError(t, bob.Import(exported, sources))
+	assert.Equal(t, uint64(7), bob.LatestSTH(log.LogID()).TreeSize)
+	err = carol.Import(exported, sources)
+	assert.True(t, errors.Is(err, errSTHConsistency))
+	assert.Error(t, carol.Import([]byte("not json"), sources))
+
+	storage := NewMemoryStorage()
+	assert.NoError(t, bob.Save(storage))
+	restored := newTestSTHStore(log)
+	assert.NoError(t, restored.Load(storage))
+	assert.Equal(t, bob.LatestSTH(log.LogID()), restored.LatestSTH(log.LogID()))
+	assert.NoError(t, newTestSTHStore(log).Load(NewMemoryStorage()))
+}
+
+func TestInclusionRecordsSTHs(t *testing.T) {
+	t.Parallel()
+	logs, publicKeys := newImpersonatingCTLogs(t)
+	for _, log := range logs {
+		_, err := log.AddChain(certificateChain)
+		assert.NoError(t, err)
+	}
+	store := newSTHStoreWithKeys(publicKeys)
+	assert.NoError(t, verifyCertificateInclusion(certificateChain, proofSources(logs), publicKeys, store))
+	for logID := range logs {
+		assert.Equal(t, uint64(1), store.LatestSTH(logID).TreeSize)
+	}
+
+	forks := make(map[string]CTLogProofSource)
+	for logID, log := range logs {
+		fork := newForkedCTLog(t, log)
+		addTestEntries(t, fork, 1, testUnrelatedCertName)
+		_, err := fork.AddChain(certificateChain)
+		assert.NoError(t, err)
+		forks[logID] = fork
+	}
+	err := verifyCertificateInclusion(certificateChain, forks, publicKeys, store)
+	assert.True(t, errors.Is(err, errSTHConsistency))
+}
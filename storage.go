@@ -0,0 +1,108 @@
+package ktclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Storage persists the client state (pinned keys, verification history...)
+// between sessions. Applications back it with their own key-value store.
+type Storage interface {
+	// Get returns the value stored under key, or nil if there is none.
+	Get(key string) ([]byte, error)
+	// Set stores value under key, replacing any previous value.
+	Set(key string, value []byte) error
+}
+
+// MemoryStorage is a Storage kept in memory, for tests and short-lived tools.
+type MemoryStorage struct {
+	mutex  sync.Mutex
+	values map[string][]byte
+}
+
+// NewMemoryStorage creates an empty MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{ //nolint:exhaustruct
+		values: make(map[string][]byte),
+	}
+}
+
+// Get implements Storage.
+func (s *MemoryStorage) Get(key string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	value, ok := s.values[key]
+	if !ok {
+		return nil, nil
+	}
+
+	return append([]byte(nil), value...), nil
+}
+
+// Set implements Storage.
+func (s *MemoryStorage) Set(key string, value []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.values[key] = append([]byte(nil), value...)
+
+	return nil
+}
+
+// DirectoryStorage is a Storage saving each value to a file of a directory,
+// for state too large to be kept in memory, such as a TreeReplay.
+type DirectoryStorage struct {
+	directory string
+}
+
+// NewDirectoryStorage creates a DirectoryStorage in directory,
+// creating it if needed.
+func NewDirectoryStorage(directory string) (*DirectoryStorage, error) {
+	if err := os.MkdirAll(directory, 0o700); err != nil {
+		return nil, errors.Wrap(err, "ktclient: create storage directory")
+	}
+
+	return &DirectoryStorage{directory: directory}, nil
+}
+
+// path returns the file of a key. Files are named after the hash of their
+// key, and spread over subdirectories.
+func (s *DirectoryStorage) path(key string) string {
+	name := sha256.Sum256([]byte(key))
+	encoded := hex.EncodeToString(name[:])
+
+	return filepath.Join(s.directory, encoded[:2], encoded[2:])
+}
+
+// Get implements Storage.
+func (s *DirectoryStorage) Get(key string) ([]byte, error) {
+	value, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "ktclient: read storage")
+	}
+
+	return value, nil
+}
+
+// Set implements Storage. The file is replaced atomically.
+func (s *DirectoryStorage) Set(key string, value []byte) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return errors.Wrap(err, "ktclient: write storage")
+	}
+	if err := os.WriteFile(path+".tmp", value, 0o600); err != nil {
+		return errors.Wrap(err, "ktclient: write storage")
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return errors.Wrap(err, "ktclient: write storage")
+	}
+
+	return nil
+}
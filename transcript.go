@@ -0,0 +1,132 @@
+package ktclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Outcomes of the steps of a Transcript.
+const (
+	TranscriptStepOK     = "ok"
+	TranscriptStepFailed = "failed"
+)
+
+// TranscriptDetail is a value a verification step used or computed.
+type TranscriptDetail struct {
+	Key   string
+	Value string
+}
+
+// TranscriptStep is a verification step recorded in a Transcript.
+type TranscriptStep struct {
+	Name     string
+	Details  []TranscriptDetail
+	Outcome  string
+	Error    string `json:",omitempty"`
+	Duration time.Duration
+}
+
+// Transcript records the steps of a verification, with their inputs,
+// outputs, outcome and duration, to understand why a verification failed.
+// It holds no secret, but holds the email addresses and keys verified:
+// it should only be attached to bug reports with the consent of the user.
+type Transcript struct {
+	mutex   sync.Mutex
+	Steps   []*TranscriptStep
+	Outcome string
+	Error   string `json:",omitempty"`
+}
+
+// transcriptRecorder records one step of a transcript.
+// A nil recorder records nothing.
+type transcriptRecorder struct {
+	step  *TranscriptStep
+	start time.Time
+}
+
+// begin starts recording a step. A nil transcript records nothing.
+func (t *Transcript) begin(name string) *transcriptRecorder {
+	if t == nil {
+		return nil
+	}
+	step := &TranscriptStep{Name: name} //nolint:exhaustruct
+	t.mutex.Lock()
+	t.Steps = append(t.Steps, step)
+	t.mutex.Unlock()
+
+	return &transcriptRecorder{step: step, start: time.Now()}
+}
+
+// finish records the outcome of the whole verification.
+func (t *Transcript) finish(err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.Outcome = TranscriptStepOK
+	if err != nil {
+		t.Outcome, t.Error = TranscriptStepFailed, err.Error()
+	}
+}
+
+func (r *transcriptRecorder) detail(key string, value interface{}) {
+	if r == nil {
+		return
+	}
+	r.step.Details = append(r.step.Details, TranscriptDetail{Key: key, Value: fmt.Sprint(value)})
+}
+
+func (r *transcriptRecorder) detailf(key string, format string, args ...interface{}) {
+	r.detail(key, fmt.Sprintf(format, args...))
+}
+
+// end records the outcome of the step, and returns err.
+func (r *transcriptRecorder) end(err error) error {
+	if r == nil {
+		return err
+	}
+	r.step.Duration = time.Since(r.start)
+	r.step.Outcome = TranscriptStepOK
+	if err != nil {
+		r.step.Outcome, r.step.Error = TranscriptStepFailed, err.Error()
+	}
+
+	return err
+}
+
+// JSON encodes the transcript in JSON.
+func (t *Transcript) JSON() ([]byte, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "ktclient: encode transcript")
+	}
+
+	return data, nil
+}
+
+// Text renders the transcript for humans, one step after the other.
+func (t *Transcript) Text() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var text strings.Builder
+	for i, step := range t.Steps {
+		fmt.Fprintf(&text, "%d. %s: %s (%v)\n", i+1, step.Name, step.Outcome, step.Duration)
+		for _, detail := range step.Details {
+			fmt.Fprintf(&text, "   %s: %s\n", detail.Key, detail.Value)
+		}
+		if step.Error != "" {
+			fmt.Fprintf(&text, "   error: %s\n", step.Error)
+		}
+	}
+	fmt.Fprintf(&text, "result: %s\n", t.Outcome)
+	if t.Error != "" {
+		fmt.Fprintf(&text, "error: %s\n", t.Error)
+	}
+
+	return text.String()
+}
@@ -0,0 +1,98 @@
+package ktclient
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func transcriptStepNames(transcript *Transcript) []string {
+	names := make([]string, 0, len(transcript.Steps))
+	for _, step := range transcript.Steps {
+		names = append(names, step.Name)
+	}
+
+	return names
+}
+
+func TestEpochTranscript(t *testing.T) {
+	t.Parallel()
+	transcript := &Transcript{} //nolint:exhaustruct
+	_, err := VerifyEpochWithTranscript(newTestEpoch(), "dev.proton.wtf", 1_689_062_800, nil, transcript)
+	assert.NoError(t, err)
+	assert.Equal(t, TranscriptStepOK, transcript.Outcome)
+	names := transcriptStepNames(transcript)
+	assert.Equal(t, "chain hash", names[0])
+	assert.Equal(t, "parse certificates", names[1])
+	assert.Equal(t, []string{"certificate chain", "alternate names"}, names[len(names)-2:])
+	for _, name := range names[2 : len(names)-2] {
+		assert.Equal(t, "SCT", name)
+	}
+	for _, step := range transcript.Steps {
+		assert.Equal(t, TranscriptStepOK, step.Outcome, step.Name)
+	}
+	text := transcript.Text()
+	assert.True(t, strings.Contains(text, "expected name: "+testEpochName), text)
+	assert.True(t, strings.Contains(text, "result: ok"), text)
+
+	data, err := transcript.JSON()
+	assert.NoError(t, err)
+	var decoded Transcript
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, transcript.Steps, decoded.Steps)
+	assert.Equal(t, TranscriptStepOK, decoded.Outcome)
+}
+
+func TestFailedEpochTranscript(t *testing.T) {
+	t.Parallel()
+	epoch := newTestEpoch()
+	epoch.TreeHash = epoch.PreviousChainHash
+	transcript := &Transcript{} //nolint:exhaustruct
+	_, err := VerifyEpochWithTranscript(epoch, "dev.proton.wtf", 1_689_062_800, nil, transcript)
+	assert.Error(t, err)
+	assert.Equal(t, TranscriptStepFailed, transcript.Outcome)
+	assert.Equal(t, err.Error(), transcript.Error)
+	assert.Equal(t, []string{"chain hash"}, transcriptStepNames(transcript))
+	assert.Equal(t, TranscriptStepFailed, transcript.Steps[0].Outcome)
+	assert.True(t, strings.Contains(transcript.Text(), "error: "))
+
+	// Verifying without a transcript behaves the same.
+	_, err = VerifyEpochWithTranscript(epoch, "dev.proton.wtf", 1_689_062_800, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestProofTranscript(t *testing.T) {
+	t.Parallel()
+	vrfKey, vrfPublicKey := newTestVRFKey(t, 1)
+	const skl, minEpochID = "[]", 7
+	sklHash := sha256.Sum256([]byte(skl))
+	leaf := sha256.Sum256(binary.BigEndian.AppendUint32(sklHash[:], minEpochID))
+	proof, rootHash := buildTestPresenceProof(t, vrfKey, "alice@proton.me", func(vrfHash []byte) []byte {
+		return TreeVersion1.treePath(vrfHash, 1)
+	}, leaf[:])
+
+	transcript := &Transcript{} //nolint:exhaustruct
+	err := VerifyInsertionProofWithTranscript(
+		" Alice@Proton.me", 1, skl, minEpochID, vrfPublicKey, rootHash, proof, nil, transcript,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"VRF public key", "email canonicalization", "VRF proof", "leaf", "root hash",
+	}, transcriptStepNames(transcript))
+	text := transcript.Text()
+	assert.True(t, strings.Contains(text, "canonical email: alice@proton.me"), text)
+	assert.True(t, strings.Contains(text, "computed root hash: "+rootHash), text)
+
+	transcript = &Transcript{} //nolint:exhaustruct
+	err = VerifyInsertionProofWithTranscript(
+		"alice@proton.me", 2, skl, minEpochID, vrfPublicKey, rootHash, proof, nil, transcript,
+	)
+	assert.Error(t, err)
+	last := transcript.Steps[len(transcript.Steps)-1]
+	assert.Equal(t, "root hash", last.Name)
+	assert.Equal(t, TranscriptStepFailed, last.Outcome)
+}
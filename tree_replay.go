@@ -0,0 +1,309 @@
+package ktclient
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// treeReplayStorageKey is the Storage key under which a TreeReplay saves its progress.
+const treeReplayStorageKey = "ktclient/tree-replay"
+
+// treeDepth is the number of levels of the sparse Merkle tree.
+const treeDepth = 256
+
+// Kinds of the nodes saved by a TreeReplay.
+const (
+	// branchNode is a node with leaves on both sides.
+	branchNode = 1
+	// shortcutNode is a node with a single leaf under it, saved in place of
+	// the chain of nodes leading to the leaf.
+	shortcutNode = 2
+)
+
+// TreeUpdate is the insertion of a key list in the tree, as published in
+// the change set of an epoch.
+type TreeUpdate struct {
+	// VRFOutput is the hex encoded VRF output of the email.
+	VRFOutput     string
+	Revision      int
+	SignedKeyList string
+	MinEpochID    int
+}
+
+// TreeReplayOptions configures a TreeReplay.
+// A nil *TreeReplayOptions selects the defaults.
+type TreeReplayOptions struct {
+	// TreeVersion is the tree model of the epochs replayed.
+	// Nil selects TreeVersionLatest.
+	TreeVersion *TreeVersion
+	// HashSuite hashes the tree nodes. Nil selects the hash suite of the
+	// tree version.
+	HashSuite HashSuite
+	// Storage holds the nodes of the tree and the progress of the replay.
+	// It defaults to a MemoryStorage; large trees need a DirectoryStorage.
+	Storage Storage
+}
+
+// treeReplayProgress is the progress a TreeReplay saves: the last replayed epoch.
+type treeReplayProgress struct {
+	EpochID  int
+	RootHash string
+}
+
+// treeNode is a node of the tree, as saved in the storage.
+type treeNode struct {
+	hash []byte
+	// path and leaf are only set for shortcut nodes.
+	path []byte
+	leaf []byte
+}
+
+// TreeReplay rebuilds the Merkle tree of key transparency from the change
+// sets of the epochs, and checks that the root hash after each epoch is
+// its TreeHash. Leaves and paths are computed as for the insertion proofs.
+// The epochs themselves should be verified separately, see Auditor.
+type TreeReplay struct {
+	treeVersion TreeVersion
+	storage     Storage
+	suite       HashSuite
+	progress    *treeReplayProgress
+	// pending holds the nodes written by the epoch being applied, until
+	// its root hash is checked.
+	pending map[string][]byte
+}
+
+// NewTreeReplay creates a TreeReplay, resuming from the progress saved to
+// the storage of the options, if any.
+func NewTreeReplay(options *TreeReplayOptions) (*TreeReplay, error) {
+	if options == nil {
+		options = &TreeReplayOptions{} //nolint:exhaustruct
+	}
+	treeVersion, err := resolveTreeVersion(options.TreeVersion)
+	if err != nil {
+		return nil, err
+	}
+	suite, err := resolveHashSuite(options.HashSuite, treeVersion)
+	if err != nil {
+		return nil, err
+	}
+	replay := &TreeReplay{ //nolint:exhaustruct
+		treeVersion: treeVersion,
+		storage:     options.Storage,
+		suite:       suite,
+	}
+	if replay.storage == nil {
+		replay.storage = NewMemoryStorage()
+	}
+	data, err := replay.storage.Get(treeReplayStorageKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "ktclient: load tree replay progress")
+	}
+	if data != nil {
+		if err := json.Unmarshal(data, &replay.progress); err != nil {
+			return nil, errors.Wrap(err, "ktclient: invalid tree replay progress")
+		}
+	}
+
+	return replay, nil
+}
+
+// EpochID returns the ID of the last epoch replayed, or 0 if none was.
+func (r *TreeReplay) EpochID() int {
+	if r.progress == nil {
+		return 0
+	}
+
+	return r.progress.EpochID
+}
+
+// RootHash returns the root hash of the tree after the last epoch replayed.
+func (r *TreeReplay) RootHash() []byte {
+	if r.progress == nil {
+		return r.suite.EmptyNode()
+	}
+	rootHash, _ := hex.DecodeString(r.progress.RootHash)
+
+	return rootHash
+}
+
+// Apply inserts the updates of the epoch in the tree, and checks that the
+// resulting root hash is the TreeHash of the epoch. The epoch must follow the
+// last epoch replayed. If the check fails, the tree is left unchanged.
+func (r *TreeReplay) Apply(epoch *Epoch, updates []TreeUpdate) error {
+	if r.progress != nil && epoch.EpochID != r.progress.EpochID+1 {
+		return fmt.Errorf(
+			"ktclient: %w: epoch %d does not follow replayed epoch %d",
+			errTreeReplay, epoch.EpochID, r.progress.EpochID,
+		)
+	}
+	treeHash, err := decodeHex(epoch.TreeHash)
+	if err != nil {
+		return errors.Wrap(err, "ktclient: invalid tree hash hex encoding")
+	}
+	r.pending = make(map[string][]byte)
+	defer func() { r.pending = nil }()
+	rootHash := r.RootHash()
+	for i, update := range updates {
+		if rootHash, err = r.applyUpdate(&update); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("ktclient: epoch %d update %d", epoch.EpochID, i))
+		}
+	}
+	if !bytes.Equal(rootHash, treeHash) {
+		return fmt.Errorf(
+			"ktclient: %w: epoch %d has tree hash %s, replayed %x",
+			errTreeReplay, epoch.EpochID, epoch.TreeHash, rootHash,
+		)
+	}
+
+	for key, value := range r.pending {
+		if err := r.storage.Set(key, value); err != nil {
+			return errors.Wrap(err, "ktclient: save tree node")
+		}
+	}
+	progress := &treeReplayProgress{EpochID: epoch.EpochID, RootHash: hex.EncodeToString(rootHash)}
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return errors.Wrap(err, "ktclient: encode tree replay progress")
+	}
+	if err := r.storage.Set(treeReplayStorageKey, data); err != nil {
+		return errors.Wrap(err, "ktclient: save tree replay progress")
+	}
+	r.progress = progress
+
+	return nil
+}
+
+// applyUpdate inserts a leaf, returning the new root hash.
+func (r *TreeReplay) applyUpdate(update *TreeUpdate) ([]byte, error) {
+	vrfHash, err := decodeHex(update.VRFOutput)
+	if err != nil {
+		return nil, errors.Wrap(err, "ktclient: invalid VRF output hex encoding")
+	}
+	if len(vrfHash) < treeDepth/8 {
+		return nil, fmt.Errorf("ktclient: %w: VRF output is too short", errTreeReplay)
+	}
+	leaf, err := computeLeafNode(
+		&InsertionProof{ProofType: presenceProofType}, //nolint:exhaustruct
+		r.suite, r.treeVersion, update.MinEpochID, update.SignedKeyList,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.insert(0, r.treeVersion.treePath(vrfHash, update.Revision), leaf)
+}
+
+// insert sets the leaf at path in the subtree at depth, returning the new
+// hash of the subtree.
+func (r *TreeReplay) insert(depth int, path, leaf []byte) ([]byte, error) {
+	node, err := r.getNode(depth, path)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil || (node.leaf != nil && bytes.Equal(node.path, path)) {
+		return r.setShortcut(depth, path, leaf)
+	}
+	if node.leaf != nil {
+		// Another leaf is under this node: move it one level down,
+		// where it may have to be split again.
+		if _, err := r.setShortcut(depth+1, node.path, node.leaf); err != nil {
+			return nil, err
+		}
+	}
+	childHash, err := r.insert(depth+1, path, leaf)
+	if err != nil {
+		return nil, err
+	}
+	sibling, err := r.getNode(depth+1, flipBit(path, depth))
+	if err != nil {
+		return nil, err
+	}
+	siblingHash := r.suite.EmptyNode()
+	if sibling != nil {
+		siblingHash = sibling.hash
+	}
+	nodeHash := r.hashChildren(childHash, siblingHash, pathBit(path, depth))
+	r.pending[treeNodeKey(depth, path)] = append([]byte{branchNode}, nodeHash...)
+
+	return nodeHash, nil
+}
+
+// setShortcut saves a shortcut node, returning its hash: the hash of the
+// leaf with empty neighbours up to depth, as in computeRootHash.
+func (r *TreeReplay) setShortcut(depth int, path, leaf []byte) ([]byte, error) {
+	nodeHash := leaf
+	for treeLevel := treeDepth - 1; treeLevel >= depth; treeLevel-- {
+		nodeHash = r.hashChildren(nodeHash, r.suite.EmptyNode(), pathBit(path, treeLevel))
+	}
+	value := append([]byte{shortcutNode}, nodeHash...)
+	value = append(value, path...)
+	r.pending[treeNodeKey(depth, path)] = append(value, leaf...)
+
+	return nodeHash, nil
+}
+
+// hashChildren hashes a node on the path and its neighbour, bit telling on
+// which side of the neighbour the node is.
+func (r *TreeReplay) hashChildren(node, neighbour []byte, bit byte) []byte {
+	if bit == 0 {
+		return r.suite.NodeHash(node, neighbour)
+	}
+
+	return r.suite.NodeHash(neighbour, node)
+}
+
+// getNode returns the node at depth on path, or nil if the subtree is empty.
+func (r *TreeReplay) getNode(depth int, path []byte) (*treeNode, error) {
+	key := treeNodeKey(depth, path)
+	value, ok := r.pending[key]
+	if !ok {
+		var err error
+		if value, err = r.storage.Get(key); err != nil {
+			return nil, errors.Wrap(err, "ktclient: load tree node")
+		}
+	}
+	if value == nil {
+		return nil, nil
+	}
+	size := r.suite.Size()
+	switch {
+	case len(value) == 1+size && value[0] == branchNode:
+		return &treeNode{hash: value[1:]}, nil //nolint:exhaustruct
+	case len(value) == 1+size+len(path)+size && value[0] == shortcutNode:
+		return &treeNode{
+			hash: value[1 : 1+size],
+			path: value[1+size : 1+size+len(path)],
+			leaf: value[1+size+len(path):],
+		}, nil
+	default:
+		return nil, fmt.Errorf("ktclient: %w: invalid node %s", errTreeReplay, key)
+	}
+}
+
+// treeNodeKey returns the Storage key of the node at depth on path.
+func treeNodeKey(depth int, path []byte) string {
+	prefix := make([]byte, (depth+7)/8)
+	copy(prefix, path)
+	if depth%8 != 0 {
+		prefix[len(prefix)-1] &= byte(0xff << (8 - depth%8))
+	}
+
+	return fmt.Sprintf("ktclient/tree/%d/%x", depth, prefix)
+}
+
+// pathBit returns the bit of the path at the given tree level.
+func pathBit(path []byte, treeLevel int) byte {
+	return (path[treeLevel/8] >> (8 - (treeLevel % 8) - 1)) & 0x01
+}
+
+// flipBit returns a copy of the path with the bit at the given tree level flipped.
+func flipBit(path []byte, treeLevel int) []byte {
+	flipped := append([]byte(nil), path...)
+	flipped[treeLevel/8] ^= 1 << (8 - (treeLevel % 8) - 1)
+
+	return flipped
+}
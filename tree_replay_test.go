@@ -0,0 +1,220 @@
+package ktclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// referenceTree computes the hashes of a tree from all its leaves,
+// level by level, without any shortcut.
+type referenceTree struct {
+	leaves  map[string][]byte
+	newHash func() hash.Hash
+}
+
+func newReferenceTree(newHash func() hash.Hash) *referenceTree {
+	return &referenceTree{leaves: make(map[string][]byte), newHash: newHash}
+}
+
+func (tree *referenceTree) subtreeHash(depth int, prefix []byte) []byte {
+	if !tree.hasLeafUnder(depth, prefix) {
+		return nil
+	}
+	if depth == treeDepth {
+		return tree.leaves[string(prefix)]
+	}
+	left := tree.subtreeHash(depth+1, prefix)
+	right := tree.subtreeHash(depth+1, flipBit(prefix, depth))
+	if pathBit(prefix, depth) == 1 {
+		left, right = right, left
+	}
+	emptyNode := make([]byte, tree.newHash().Size())
+	if left == nil {
+		left = emptyNode
+	}
+	if right == nil {
+		right = emptyNode
+	}
+	hashFunc := tree.newHash()
+	hashFunc.Write(left)  //nolint:errcheck
+	hashFunc.Write(right) //nolint:errcheck
+
+	return hashFunc.Sum(nil)
+}
+
+func (tree *referenceTree) hasLeafUnder(depth int, prefix []byte) bool {
+	for path := range tree.leaves {
+		if treeNodeKey(depth, []byte(path)) == treeNodeKey(depth, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (tree *referenceTree) rootHash() []byte {
+	rootHash := tree.subtreeHash(0, make([]byte, 32))
+	if rootHash == nil {
+		return make([]byte, tree.newHash().Size())
+	}
+
+	return rootHash
+}
+
+// proof returns an insertion proof of the path, with the neighbours found in the tree.
+func (tree *referenceTree) proof(path []byte) *InsertionProof {
+	proof := &InsertionProof{Neighbours: make(map[uint8][]byte)} //nolint:exhaustruct
+	for treeLevel := 0; treeLevel < treeDepth; treeLevel++ {
+		if neighbour := tree.subtreeHash(treeLevel+1, flipBit(path, treeLevel)); neighbour != nil {
+			proof.Neighbours[uint8(treeLevel)] = neighbour
+		}
+	}
+
+	return proof
+}
+
+func testTreeUpdate(email string, revision int, signedKeyList string) TreeUpdate {
+	vrfOutput := sha256.Sum256([]byte(email))
+
+	return TreeUpdate{
+		VRFOutput:     hex.EncodeToString(append(vrfOutput[:], vrfOutput[:]...)),
+		Revision:      revision,
+		SignedKeyList: signedKeyList,
+		MinEpochID:    revision,
+	}
+}
+
+// applyToReference inserts the updates in the reference tree.
+func applyToReference(t *testing.T, tree *referenceTree, treeVersion TreeVersion, updates []TreeUpdate) {
+	t.Helper()
+	for _, update := range updates {
+		vrfHash, _ := hex.DecodeString(update.VRFOutput)
+		leaf, err := computeLeafNode(
+			&InsertionProof{ProofType: presenceProofType}, //nolint:exhaustruct
+			NewHashSuite("reference", tree.newHash), treeVersion, update.MinEpochID, update.SignedKeyList,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.leaves[string(treeVersion.treePath(vrfHash, update.Revision))] = leaf
+	}
+}
+
+func testEpochUpdates() [][]TreeUpdate {
+	return [][]TreeUpdate{
+		{
+			testTreeUpdate("alice@proton.me", 1, "alice 1"),
+			testTreeUpdate("bob@proton.me", 1, "bob 1"),
+		},
+		{},
+		{
+			testTreeUpdate("alice@proton.me", 2, "alice 2"),
+			testTreeUpdate("carol@proton.me", 1, "carol 1"),
+			// Replaces the leaf inserted earlier in the epoch.
+			testTreeUpdate("carol@proton.me", 1, "carol 1 obsolete"),
+			testTreeUpdate("dave@proton.me", 1, "dave 1"),
+		},
+	}
+}
+
+func TestTreeReplay(t *testing.T) {
+	t.Parallel()
+	for _, treeVersion := range []TreeVersion{TreeVersion0, TreeVersion1} {
+		treeVersion := treeVersion
+		t.Run(fmt.Sprint(treeVersion), func(t *testing.T) {
+			t.Parallel()
+			replay, err := NewTreeReplay(&TreeReplayOptions{TreeVersion: testTreeVersion(treeVersion)}) //nolint:exhaustruct
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, make([]byte, sha256.Size), replay.RootHash())
+			tree := newReferenceTree(sha256.New)
+			for i, updates := range testEpochUpdates() {
+				applyToReference(t, tree, treeVersion, updates)
+				epoch := &Epoch{EpochID: 10 + i, TreeHash: hex.EncodeToString(tree.rootHash())} //nolint:exhaustruct
+				assert.NoError(t, replay.Apply(epoch, updates))
+				assert.Equal(t, tree.rootHash(), replay.RootHash())
+				assert.Equal(t, 10+i, replay.EpochID())
+			}
+
+			// The replayed root hash is the one the insertion proofs lead to.
+			for path, leaf := range tree.leaves {
+				proof := tree.proof([]byte(path))
+				proof.ProofType = presenceProofType
+				rootHash, err := computeRootHash([]byte(path), proof, leaf, SHA256HashSuite())
+				assert.NoError(t, err)
+				assert.Equal(t, replay.RootHash(), rootHash)
+			}
+			absentPath := sha256.Sum256([]byte("absent"))
+			proof := tree.proof(absentPath[:])
+			proof.ProofType = absenceProofType
+			rootHash, err := computeRootHash(absentPath[:], proof, make([]byte, sha256.Size), SHA256HashSuite())
+			assert.NoError(t, err)
+			assert.Equal(t, replay.RootHash(), rootHash)
+		})
+	}
+}
+
+func TestTreeReplayRejectsWrongTreeHash(t *testing.T) {
+	t.Parallel()
+	storage := NewMemoryStorage()
+	replay, err := NewTreeReplay(&TreeReplayOptions{Storage: storage}) //nolint:exhaustruct
+	if err != nil {
+		t.Fatal(err)
+	}
+	epochUpdates := testEpochUpdates()
+	tree := newReferenceTree(sha256.New)
+	applyToReference(t, tree, TreeVersion1, epochUpdates[0])
+	assert.NoError(t, replay.Apply(&Epoch{EpochID: 1, TreeHash: hex.EncodeToString(tree.rootHash())}, epochUpdates[0])) //nolint:exhaustruct
+
+	// An update missing from the change set.
+	rootHash := replay.RootHash()
+	applyToReference(t, tree, TreeVersion1, epochUpdates[2])
+	epoch := &Epoch{EpochID: 2, TreeHash: hex.EncodeToString(tree.rootHash())} //nolint:exhaustruct
+	err = replay.Apply(epoch, epochUpdates[2][1:])
+	assert.True(t, errors.Is(err, errTreeReplay), err)
+	assert.Equal(t, rootHash, replay.RootHash())
+	assert.Equal(t, 1, replay.EpochID())
+
+	// The tree is left unchanged, so the epoch can be applied again.
+	assert.NoError(t, replay.Apply(epoch, epochUpdates[2]))
+
+	assert.True(t, errors.Is(replay.Apply(epoch, nil), errTreeReplay))
+	err = replay.Apply(&Epoch{EpochID: 3, TreeHash: "zz"}, nil) //nolint:exhaustruct
+	assert.Error(t, err)
+	update := testTreeUpdate("eve@proton.me", 1, "eve 1")
+	update.VRFOutput = update.VRFOutput[:40]
+	err = replay.Apply(&Epoch{EpochID: 3, TreeHash: epoch.TreeHash}, []TreeUpdate{update}) //nolint:exhaustruct
+	assert.True(t, errors.Is(err, errTreeReplay), err)
+}
+
+func TestTreeReplayResumesFromDirectory(t *testing.T) {
+	t.Parallel()
+	storage, err := NewDirectoryStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	epochUpdates := testEpochUpdates()
+	tree := newReferenceTree(sha256.New)
+	replay, err := NewTreeReplay(&TreeReplayOptions{Storage: storage}) //nolint:exhaustruct
+	if err != nil {
+		t.Fatal(err)
+	}
+	applyToReference(t, tree, TreeVersion1, epochUpdates[0])
+	assert.NoError(t, replay.Apply(&Epoch{EpochID: 1, TreeHash: hex.EncodeToString(tree.rootHash())}, epochUpdates[0])) //nolint:exhaustruct
+
+	replay, err = NewTreeReplay(&TreeReplayOptions{Storage: storage}) //nolint:exhaustruct
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, replay.EpochID())
+	assert.Equal(t, tree.rootHash(), replay.RootHash())
+	applyToReference(t, tree, TreeVersion1, epochUpdates[2])
+	assert.NoError(t, replay.Apply(&Epoch{EpochID: 2, TreeHash: hex.EncodeToString(tree.rootHash())}, epochUpdates[2])) //nolint:exhaustruct
+}
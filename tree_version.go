@@ -0,0 +1,66 @@
+package ktclient
+
+import (
+	"fmt"
+)
+
+// TreeVersion identifies the Merkle tree model of key transparency. Its value
+// is the epoch name version of the matching epoch certificates.
+// Proofs and epochs must be verified with the version they were produced with.
+type TreeVersion int
+
+const (
+	// TreeVersion0 is the model before 1.0.0: the VRF output is the tree path
+	// and the leaf is the hash of the signed key list.
+	// Its epoch certificates use the name version 0.
+	//
+	// This model is unverified: it follows the description of the 1.0.0
+	// changes, and has not been checked against proofs of a version 0 server.
+	TreeVersion0 TreeVersion = 0
+	// TreeVersion1 is the model since 1.0.0: the revision is in the tree path
+	// and the MinEpochID in the leaf.
+	// Its epoch certificates use the name version 1.
+	TreeVersion1 TreeVersion = 1
+)
+
+// TreeVersionLatest is the current tree model, selected by a nil
+// TreeVersion in the options.
+const TreeVersionLatest = TreeVersion1
+
+// resolveTreeVersion returns the version an options field selects,
+// TreeVersionLatest if it is nil, and rejects unknown versions.
+func resolveTreeVersion(version *TreeVersion) (TreeVersion, error) {
+	if version == nil {
+		return TreeVersionLatest, nil
+	}
+	switch *version {
+	case TreeVersion0, TreeVersion1:
+		return *version, nil
+	default:
+		return 0, fmt.Errorf("ktclient: %w: %d", errTreeVersion, *version)
+	}
+}
+
+// nameVersion returns the version number found in the alternate names
+// of the epoch certificates of a resolved tree version.
+func (v TreeVersion) nameVersion() int {
+	return int(v)
+}
+
+// treePath returns the path of a key list in the tree of a resolved version.
+func (v TreeVersion) treePath(vrfHash []byte, revision int) []byte {
+	if v == TreeVersion0 {
+		return vrfHash[0:32]
+	}
+
+	return append(
+		vrfHash[0:28:28],
+		byte(revision>>24), byte(revision>>16),
+		byte(revision>>8), byte(revision),
+	)
+}
+
+// hashSuite returns the hash suite of a resolved tree version.
+func (v TreeVersion) hashSuite() HashSuite {
+	return SHA256HashSuite()
+}
@@ -0,0 +1,163 @@
+package ktclient
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/ProtonMail/go-ecvrf/ecvrf"
+	"github.com/stretchr/testify/assert"
+)
+
+const testLegacySKL = `[{"Primary":1,"Flags":3,"Fingerprint":"43eb8f7cc59576c0bca4414258518450b9119b5d"}]`
+
+// testTreeVersion returns a pointer to version, to select it in options.
+func testTreeVersion(version TreeVersion) *TreeVersion {
+	return &version
+}
+
+// newTestVRFKey returns a deterministic VRF key pair for building proofs.
+func newTestVRFKey(t *testing.T, seed byte) (*ecvrf.PrivateKey, string) {
+	t.Helper()
+	seedBytes := make([]byte, ed25519.SeedSize)
+	seedBytes[0] = seed
+	privateKey, err := ecvrf.NewPrivateKey(ed25519.NewKeyFromSeed(seedBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := privateKey.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return privateKey, base64.StdEncoding.EncodeToString(publicKey.Bytes())
+}
+
+// buildTestPresenceProof builds a presence proof for a tree holding only the
+// given leaf and one other node next to the root, and returns the root hash.
+func buildTestPresenceProof(
+	t *testing.T,
+	vrfKey *ecvrf.PrivateKey,
+	email string,
+	path func(vrfHash []byte) []byte,
+	leaf []byte,
+) (*InsertionProof, string) {
+	t.Helper()
+	vrfHash, vrfProof, err := vrfKey.Prove([]byte(email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	treePath := path(vrfHash)
+	sibling := sha256.Sum256([]byte("sibling"))
+	current := leaf
+	for level := 255; level >= 0; level-- {
+		neighbour := make([]byte, sha256.Size)
+		if level == 0 {
+			neighbour = sibling[:]
+		}
+		var concat []byte
+		if (treePath[level/8]>>(7-level%8))&1 == 0 {
+			concat = append(append(concat, current...), neighbour...)
+		} else {
+			concat = append(append(concat, neighbour...), current...)
+		}
+		sum := sha256.Sum256(concat)
+		current = sum[:]
+	}
+
+	return &InsertionProof{
+		ProofType:   presenceProofType,
+		VRFProofHex: hex.EncodeToString(vrfProof),
+		Neighbours:  map[uint8][]byte{0: sibling[:]},
+	}, hex.EncodeToString(current)
+}
+
+func TestLegacyTreeVersionProof(t *testing.T) {
+	t.Parallel()
+	vrfKey, vrfPublicKey := newTestVRFKey(t, 1)
+	email := "legacy@proton.black"
+	leaf := sha256.Sum256([]byte(testLegacySKL))
+	proof, rootHash := buildTestPresenceProof(t, vrfKey, email, func(vrfHash []byte) []byte {
+		return vrfHash[:32]
+	}, leaf[:])
+
+	verify := func(version *TreeVersion) error {
+		return VerifyInsertionProofWithOptions(
+			email, 3, testLegacySKL, 10, vrfPublicKey, rootHash, proof,
+			&ProofOptions{TreeVersion: version}, //nolint:exhaustruct
+		)
+	}
+	assert.NoError(t, verify(testTreeVersion(TreeVersion0)))
+	assert.True(t, errors.Is(verify(testTreeVersion(TreeVersion1)), errIntegrity))
+	assert.True(t, errors.Is(verify(nil), errIntegrity))
+	assert.True(t, errors.Is(verify(testTreeVersion(42)), errTreeVersion))
+}
+
+func TestTreeVersionNameVersion(t *testing.T) {
+	t.Parallel()
+	for _, nameVersion := range []int{0, 1} {
+		version, err := resolveTreeVersion(testTreeVersion(TreeVersion(nameVersion)))
+		assert.NoError(t, err)
+		assert.Equal(t, nameVersion, version.nameVersion())
+	}
+	version, err := resolveTreeVersion(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, TreeVersion1, version)
+	_, err = resolveTreeVersion(testTreeVersion(2))
+	assert.True(t, errors.Is(err, errTreeVersion), err)
+}
+
+func TestCurrentProofFailsWithLegacyTreeVersion(t *testing.T) {
+	t.Parallel()
+	testData := newTestSKLProofData()
+	proof, err := testData.getProof()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		version  *TreeVersion
+		expected bool
+	}{
+		{nil, true},
+		{testTreeVersion(TreeVersion1), true},
+		{testTreeVersion(TreeVersion0), false},
+	} {
+		err = VerifyInsertionProofWithOptions(
+			testData.email,
+			testData.revision,
+			testData.signedKeyList,
+			testData.minEpochID,
+			testVRFPublicKey,
+			testData.rootHash,
+			proof,
+			&ProofOptions{TreeVersion: test.version}, //nolint:exhaustruct
+		)
+		assert.Equal(t, test.expected, err == nil, "tree version %v", test.version)
+	}
+}
+
+func TestEpochNameVersion(t *testing.T) {
+	t.Parallel()
+	epoch := Epoch{
+		EpochID:           46,
+		PreviousChainHash: "9624e880fe4b49b45fc15ca7e16b7ed8a846724a1802b2a2ca9d749770b00f4b",
+		CertificateChain:  certificateChain,
+		CertificateIssuer: 1,
+		TreeHash:          "65e8dd7b133f7a02fe0e249928f7fdf1a21df8a5923234c97e0177f0f56a194c",
+		ChainHash:         "506062a81b4f2ae8aeb2f6dd2d003adac3cd1e84a39d19225a8653d1012cf0d2",
+		CertificateTime:   1_689_062_740,
+	}
+	verify := func(version TreeVersion) error {
+		_, err := VerifyEpochWithOptions(&epoch, "dev.proton.wtf", 1_689_062_740, &EpochOptions{ //nolint:exhaustruct
+			TreeVersion: testTreeVersion(version),
+		})
+
+		return err
+	}
+	assert.NoError(t, verify(TreeVersion1))
+	err := verify(TreeVersion0)
+	assert.True(t, errors.Is(err, errCert))
+}
@@ -2,11 +2,11 @@ package ktclient
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"strings"
 	"time"
 
 	ct "github.com/google/certificate-transparency-go"
@@ -26,6 +26,74 @@ type Epoch struct {
 	TreeHash          string
 	ChainHash         string
 	CertificateTime   int64
+	// Signature is only set by private deployments, see VerifySignedEpoch.
+	Signature string
+	// Cosignatures holds the signature lines of the epoch checkpoint signed
+	// by witnesses, if any, see WitnessPolicy.
+	Cosignatures string
+}
+
+// EpochOptions tunes how VerifyEpochWithOptions verifies an epoch.
+// A nil *EpochOptions selects the defaults.
+type EpochOptions struct {
+	// TreeVersion is the tree model of the epoch, which selects the
+	// expected epoch name version. Nil selects TreeVersionLatest.
+	TreeVersion *TreeVersion
+	// HashSuite computes the chain hash. Nil selects the hash suite of the
+	// tree version.
+	HashSuite HashSuite
+	// CTLogs, if not nil, also requires the certificate to be included in
+	// the CT logs that issued its SCTs, see VerifyCertificateInclusion.
+	CTLogs map[string]CTLogProofSource
+	// CTLogPublicKeys, if not nil, holds the base64 encoded DER public keys
+	// of the CTLogs, by base64 log ID, to verify their signed tree heads.
+	// Nil selects the keys of the embedded CT log list.
+	CTLogPublicKeys map[string]string
+	// STHStore, if not nil, records the tree heads of the CTLogs used to
+	// verify the inclusion, and fails if they are inconsistent with the
+	// tree heads seen before.
+	STHStore *STHStore
+	// WitnessPolicy, if not nil, also requires the epoch to be cosigned
+	// by a quorum of witnesses.
+	WitnessPolicy *WitnessPolicy
+	// Revocation, if not nil, checks the revocation status of the epoch
+	// certificate with the OCSP response or CRL it carries.
+	Revocation *RevocationOptions
+	// StrictAlternateNames requires the alternate names of the epoch
+	// certificate to be exactly the epoch name and epoch.<id>.<version>.<baseDomain>,
+	// reporting violations with an *AlternateNameError.
+	StrictAlternateNames bool
+	// Freshness, if not nil, rejects epochs that are too old, with
+	// ErrStaleEpoch, or whose times are inconsistent with the clock
+	// or their certificate.
+	Freshness *FreshnessPolicy
+	// Clock, if not nil, tells the time instead of the currentUnixTime
+	// parameter of the verification functions.
+	Clock Clock
+
+	transcript *Transcript
+}
+
+// ctPublicKeys returns the public keys of the CTLogs of the options.
+func (o *EpochOptions) ctPublicKeys() (map[string]ctPublicKey, error) {
+	if o.CTLogPublicKeys == nil {
+		return parseCTPublicKeys(ctLogs)
+	}
+	publicKeys := make(map[string]ctPublicKey, len(o.CTLogPublicKeys))
+	for logID, publicKey := range o.CTLogPublicKeys {
+		publicKeys[logID] = ctPublicKey{OperatorName: logID, PublicKey: publicKey}
+	}
+
+	return publicKeys, nil
+}
+
+// clock returns the clock of the options, or the clock currentUnixTime stands for.
+func (o *EpochOptions) clock(currentUnixTime int64) Clock {
+	if o.Clock != nil {
+		return o.Clock
+	}
+
+	return unixTimeClock(currentUnixTime)
 }
 
 // VerifyEpoch will verify the epoch's certificate, the CT log signature
@@ -36,44 +104,248 @@ func VerifyEpoch(
 	baseDomain string,
 	currentUnixTime int64,
 ) (int64, error) {
-	// (a) Parse certificates
-	cert, rest, err := convertPEMEncodedCertToX509Cert([]byte(epoch.CertificateChain))
+	return VerifyEpochWithOptions(epoch, baseDomain, currentUnixTime, nil)
+}
+
+// VerifyEpochWithOptions is VerifyEpoch with explicit verification options.
+func VerifyEpochWithOptions(
+	epoch *Epoch,
+	baseDomain string,
+	currentUnixTime int64,
+	options *EpochOptions,
+) (int64, error) {
+	return verifyEpoch(epoch, baseDomain, currentUnixTime, options, verifyCertificateAttestation)
+}
+
+// VerifyEpochWithTranscript is VerifyEpochWithOptions, also recording
+// the steps of the verification in the transcript, whether it succeeds or not.
+func VerifyEpochWithTranscript(
+	epoch *Epoch,
+	baseDomain string,
+	currentUnixTime int64,
+	options *EpochOptions,
+	transcript *Transcript,
+) (int64, error) {
+	var recorded EpochOptions
+	if options != nil {
+		recorded = *options
+	}
+	recorded.transcript = transcript
+	notBefore, err := VerifyEpochWithOptions(epoch, baseDomain, currentUnixTime, &recorded)
+	if transcript != nil {
+		transcript.finish(err)
+	}
+
+	return notBefore, err
+}
+
+// attestedEpoch is an epoch whose chain hash was verified,
+// and that must be attested under its epoch name.
+type attestedEpoch struct {
+	epoch       *Epoch
+	chainHash   []byte
+	nameVersion int
+	baseDomain  string
+}
+
+func (a *attestedEpoch) name() string {
+	return epochName(a.chainHash, a.epoch.CertificateTime, a.epoch.EpochID, a.nameVersion, a.baseDomain)
+}
+
+// epochAttestation verifies that an epoch is attested,
+// and returns the time from which the attestation is valid.
+type epochAttestation func(epoch *attestedEpoch, clock Clock, options *EpochOptions) (int64, error)
+
+// verifyEpoch runs the verification steps shared by all the ways to
+// attest an epoch, and verifies its attestation.
+func verifyEpoch(
+	epoch *Epoch,
+	baseDomain string,
+	currentUnixTime int64,
+	options *EpochOptions,
+	attestation epochAttestation,
+) (int64, error) {
+	if options == nil {
+		options = &EpochOptions{} //nolint:exhaustruct
+	}
+	treeVersion, err := resolveTreeVersion(options.TreeVersion)
 	if err != nil {
 		return 0, err
 	}
-
-	// (b) Verify CT signatures from second certificate against ct_logs
-	signingCertPEM, _ := pem.Decode(rest) // reuse 'rest' for step (c)
-	signingCert, err := x509.ParseCertificate(signingCertPEM.Bytes)
+	suite, err := resolveHashSuite(options.HashSuite, treeVersion)
 	if err != nil {
-		return 0, errors.Wrap(err, "ktclient: cannot parse cert")
+		return 0, err
 	}
-	if err = verifySCT(cert, signingCert); err != nil {
+	clock := options.clock(currentUnixTime)
+
+	// Check that hash(previous_hash || rootHash) = chainHash,
+	step := options.transcript.begin("chain hash")
+	step.detail("epoch ID", epoch.EpochID)
+	step.detail("previous chain hash", epoch.PreviousChainHash)
+	step.detail("tree hash", epoch.TreeHash)
+	step.detail("chain hash", epoch.ChainHash)
+	step.detail("hash suite", suite.Name())
+	chainHash, err := verifyChainHash(epoch, suite)
+	if err = step.end(err); err != nil {
 		return 0, err
 	}
 
-	// (c) Verify certificate chain (leading to hardcoded LE certificate)
-	err = verifyCertificateChain(epoch.CertificateIssuer, cert, rest, currentUnixTime)
-	if err != nil {
-		return 0, err
+	if options.Freshness != nil {
+		step = options.transcript.begin("epoch age")
+		step.detail("certificate time", epoch.CertificateTime)
+		step.detail("current time", clock.Now().Unix())
+		if err = step.end(options.Freshness.verifyEpochAge(epoch, clock.Now())); err != nil {
+			return 0, err
+		}
 	}
 
-	// (d) Check that hash(previous_hash || rootHash) = chainHash,
-	chainHash, err := verifyChainHash(epoch)
+	if options.WitnessPolicy != nil {
+		step = options.transcript.begin("witness cosignatures")
+		checkpointBody := epochCheckpointBody(epoch.EpochID, chainHash, epoch.CertificateTime, baseDomain)
+		step.detail("checkpoint", checkpointBody)
+		err = options.WitnessPolicy.verifyCosignatures(checkpointBody, epoch.Cosignatures)
+		if err = step.end(err); err != nil {
+			return 0, err
+		}
+	}
+
+	return attestation(&attestedEpoch{
+		epoch:       epoch,
+		chainHash:   chainHash,
+		nameVersion: treeVersion.nameVersion(),
+		baseDomain:  baseDomain,
+	}, clock, options)
+}
+
+// verifyCertificateAttestation verifies the epoch certificate,
+// and returns its NotBefore value.
+func verifyCertificateAttestation(epoch *attestedEpoch, clock Clock, options *EpochOptions) (int64, error) {
+	// (a), (b), (c) Parse and verify the certificate chain and its SCTs
+	currentTime := clock.Now()
+	cert, err := verifyEpochCertificate(
+		epoch.epoch.CertificateChain,
+		epoch.epoch.CertificateIssuer,
+		currentTime,
+		options.transcript,
+	)
 	if err != nil {
 		return 0, err
 	}
+	if options.CTLogs != nil {
+		step := options.transcript.begin("CT log inclusion")
+		publicKeys, err := options.ctPublicKeys()
+		if err == nil {
+			err = verifyCertificateInclusion(epoch.epoch.CertificateChain, options.CTLogs, publicKeys, options.STHStore)
+		}
+		if err = step.end(err); err != nil {
+			return 0, err
+		}
+	}
+	if options.Revocation != nil {
+		step := options.transcript.begin("revocation")
+		step.detail("policy", options.Revocation.Policy)
+		step.detail("OCSP response", len(options.Revocation.OCSPResponse) > 0)
+		step.detail("CRL", len(options.Revocation.CRL) > 0)
+		err = verifyRevocation(epoch.epoch.CertificateChain, currentTime, options.Revocation)
+		if err = step.end(err); err != nil {
+			return 0, err
+		}
+	}
+	if options.Freshness != nil {
+		step := options.transcript.begin("certificate times")
+		step.detail("certificate time", epoch.epoch.CertificateTime)
+		step.detail("not before", cert.NotBefore.Unix())
+		step.detail("not after", cert.NotAfter.Unix())
+		err = options.Freshness.verifyCertificateTimes(epoch.epoch, cert, currentTime)
+		if err = step.end(err); err != nil {
+			return 0, err
+		}
+	}
+
+	// (d) The chain hash is checked by verifyEpoch
 
 	// (e) Verify that the Subject Alternate Name values contain the chain hash
-	err = verifyAlternateName(cert, chainHash, epoch.EpochID, epoch.CertificateTime, baseDomain)
-	if err != nil {
+	step := options.transcript.begin("alternate names")
+	step.detail("expected name", epoch.name())
+	step.detail("names", strings.Join(cert.DNSNames, ", "))
+	step.detail("strict", options.StrictAlternateNames)
+	if options.StrictAlternateNames {
+		err = verifyStrictAlternateNames(cert, epoch)
+	} else {
+		err = verifyAlternateName(cert, epoch)
+	}
+	if err = step.end(err); err != nil {
 		return 0, err
 	}
 
 	return cert.NotBefore.Unix(), nil
 }
 
-func verifyCertificateChain(certificateIssuer int, cert *x509.Certificate, rest []byte, currentUnixTime int64) error {
+// verifyEpochCertificate parses the PEM encoded certificate chain, and verifies
+// the SCTs and the chain of its leaf certificate, which it returns.
+func verifyEpochCertificate(
+	certificateChain string,
+	certificateIssuer int,
+	currentTime time.Time,
+	transcript *Transcript,
+) (*x509.Certificate, error) {
+	// (a) Parse certificates
+	step := transcript.begin("parse certificates")
+	cert, signingCert, rest, err := parseEpochCertificates(certificateChain)
+	if err == nil {
+		step.detail("subject", cert.Subject.CommonName)
+		step.detailf("serial number", "%x", cert.SerialNumber)
+		step.detail("not before", cert.NotBefore.Unix())
+		step.detail("not after", cert.NotAfter.Unix())
+		step.detail("issuer", signingCert.Subject.CommonName)
+	}
+	if err = step.end(err); err != nil {
+		return nil, err
+	}
+
+	// (b) Verify CT signatures from second certificate against ct_logs
+	if err = verifySCT(cert, signingCert, transcript); err != nil {
+		return nil, err
+	}
+
+	// (c) Verify certificate chain (leading to hardcoded LE certificate)
+	step = transcript.begin("certificate chain")
+	step.detail("issuer code", certificateIssuer)
+	step.detail("current time", currentTime.Unix())
+	err = verifyCertificateChain(certificateIssuer, cert, rest, currentTime, step)
+	if err = step.end(err); err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
+
+// parseEpochCertificates parses the leaf and issuer certificates of the
+// PEM encoded chain, and also returns the PEM encoded certificates after the leaf.
+func parseEpochCertificates(certificateChain string) (*x509.Certificate, *x509.Certificate, []byte, error) {
+	cert, rest, err := convertPEMEncodedCertToX509Cert([]byte(certificateChain))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	signingCertPEM, _ := pem.Decode(rest) // reuse 'rest' for step (c)
+	if signingCertPEM == nil {
+		return nil, nil, nil, fmt.Errorf("ktclient: %w: missing issuer certificate", errCert)
+	}
+	signingCert, err := x509.ParseCertificate(signingCertPEM.Bytes)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "ktclient: cannot parse cert")
+	}
+
+	return cert, signingCert, rest, nil
+}
+
+func verifyCertificateChain(
+	certificateIssuer int,
+	cert *x509.Certificate,
+	rest []byte,
+	currentTime time.Time,
+	step *transcriptRecorder,
+) error {
 	var certPEM []byte
 	switch certificateIssuer {
 	case letsEncryptIssuer:
@@ -87,25 +359,27 @@ func verifyCertificateChain(certificateIssuer int, cert *x509.Certificate, rest
 	intermediates, roots := x509.NewCertPool(), x509.NewCertPool()
 	intermediates.AppendCertsFromPEM(rest)
 	roots.AppendCertsFromPEM(certPEM)
-	var currentTime time.Time
-	if currentUnixTime <= 0 {
-		currentTime = time.Now()
-	} else {
-		currentTime = time.Unix(currentUnixTime, 0)
-	}
 	verOpts := x509.VerifyOptions{ //nolint:exhaustruct
 		Roots:         roots,
 		Intermediates: intermediates,
 		CurrentTime:   currentTime,
 	}
-	if _, err := cert.Verify(verOpts); err != nil {
+	chains, err := cert.Verify(verOpts)
+	if err != nil {
 		return errors.Wrap(err, "ktclient: inconsistent certificate chain")
 	}
+	for _, chain := range chains {
+		path := make([]string, 0, len(chain))
+		for _, chainCert := range chain {
+			path = append(path, chainCert.Subject.CommonName)
+		}
+		step.detail("path", strings.Join(path, " -> "))
+	}
 
 	return nil
 }
 
-func verifyChainHash(epoch *Epoch) ([]byte, error) {
+func verifyChainHash(epoch *Epoch, suite HashSuite) ([]byte, error) {
 	previousChainHash, err := decodeHex(epoch.PreviousChainHash)
 	if err != nil {
 		return nil, errors.Wrap(err, "ktclient: invalid encoding of previous chain hash")
@@ -118,37 +392,15 @@ func verifyChainHash(epoch *Epoch) ([]byte, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "ktclient: invalid encoding of chain hash")
 	}
-	concat := append(previousChainHash, rootHash...)
-	hashFunc := sha256.New()
-	hashFunc.Reset()
-	_, err = hashFunc.Write(concat)
-	if err != nil {
-		return nil, errors.Wrap(err, "ktclient: error while hashing")
-	}
-	if !bytes.Equal(chainHash, hashFunc.Sum(nil)) {
-		return nil, fmt.Errorf("%w: inconsistent chainHash", errIntegrity)
+	if computed := suite.ChainHash(previousChainHash, rootHash); !bytes.Equal(chainHash, computed) {
+		return nil, fmt.Errorf("%w: inconsistent chainHash, computed %x", errIntegrity, computed)
 	}
 
 	return chainHash, nil
 }
 
-func verifyAlternateName(
-	cert *x509.Certificate,
-	chainHash []byte,
-	epochID int,
-	certificateTime int64,
-	baseDomain string,
-) error {
-	hashStr := fmt.Sprintf("%x", chainHash)
-	expectedName := fmt.Sprintf(
-		"%s.%s.%d.%d.%d.%s",
-		hashStr[:32],
-		hashStr[32:],
-		certificateTime,
-		epochID,
-		nameVersion,
-		baseDomain,
-	)
+func verifyAlternateName(cert *x509.Certificate, epoch *attestedEpoch) error {
+	expectedName := epoch.name()
 	found := false
 	for _, altName := range cert.DNSNames {
 		if altName == expectedName {
@@ -165,7 +417,7 @@ func verifyAlternateName(
 }
 
 // See RFC 6962, sections 3.1 and 3.2.
-func verifySCT(cert, leCert *x509.Certificate) error {
+func verifySCT(cert, leCert *x509.Certificate, transcript *Transcript) error {
 	publicKeys, err := parseCTPublicKeys(ctLogs)
 	if err != nil {
 		return err
@@ -184,27 +436,32 @@ func verifySCT(cert, leCert *x509.Certificate) error {
 
 	for _, sct := range scts {
 		logID := base64.StdEncoding.EncodeToString(sct.LogID.KeyID[:])
+		step := transcript.begin("SCT")
+		step.detail("log ID", logID)
+		step.detail("timestamp", sct.Timestamp)
 		key, ok := publicKeys[logID]
 		if !ok {
 			err := fmt.Errorf("ktclient: %w: no public key available", errSCT)
-			sctErrors = append(sctErrors, err)
+			sctErrors = append(sctErrors, step.end(err))
 
 			continue
 		}
+		step.detail("operator", key.OperatorName)
 		publicKey, err := ct.PublicKeyFromB64(key.PublicKey)
 		if err != nil {
 			err := fmt.Errorf("ktclient: %w: cannot parse public key: %w", errSCT, err)
-			sctErrors = append(sctErrors, err)
+			sctErrors = append(sctErrors, step.end(err))
 
 			continue
 		}
 		err = ctutil.VerifySCT(publicKey, []*x509.Certificate{cert, leCert}, sct, true)
 		if err != nil {
 			err := errors.Wrap(err, fmt.Sprintf("ktclient: SCT with log ID %s", logID))
-			sctErrors = append(sctErrors, err)
+			sctErrors = append(sctErrors, step.end(err))
 
 			continue
 		}
+		_ = step.end(nil)
 		operators[key.OperatorName] = true
 	}
 
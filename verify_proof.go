@@ -3,10 +3,9 @@ package ktclient
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"fmt"
-	"hash"
 
+	"github.com/ProtonMail/go-ecvrf/ecvrf"
 	"github.com/pkg/errors"
 )
 
@@ -18,9 +17,26 @@ type InsertionProof struct {
 	Neighbours  map[uint8][]byte
 }
 
+// ProofOptions tunes how VerifyInsertionProofWithOptions verifies a proof.
+// A nil *ProofOptions selects the defaults.
+type ProofOptions struct {
+	// SkipEmailCanonicalization feeds the email to the VRF as given,
+	// instead of its CanonicalizeEmail form.
+	SkipEmailCanonicalization bool
+	// TreeVersion is the tree model the proof was produced with.
+	// Nil selects TreeVersionLatest.
+	TreeVersion *TreeVersion
+	// HashSuite hashes the tree nodes. Nil selects the hash suite of the
+	// tree version.
+	HashSuite HashSuite
+
+	transcript *Transcript
+}
+
 // VerifyInsertionProof verifies that the signed key list
 // is correctly inserted in the merkle tree, at the correct location
 // associated with the VRF output for the given email.
+// The email is canonicalized before the VRF verification.
 func VerifyInsertionProof(
 	email string,
 	revision int,
@@ -30,25 +46,137 @@ func VerifyInsertionProof(
 	rootHashHex string,
 	proof *InsertionProof,
 ) error {
-	vrfHash, err := verifyVRFOutput(email, proof.VRFProofHex, vrfPublicKeyBase64)
+	return VerifyInsertionProofWithOptions(
+		email,
+		revision,
+		signedKeyList,
+		minEpochID,
+		vrfPublicKeyBase64,
+		rootHashHex,
+		proof,
+		nil,
+	)
+}
+
+// VerifyInsertionProofWithOptions is VerifyInsertionProof with
+// explicit verification options.
+func VerifyInsertionProofWithOptions(
+	email string,
+	revision int,
+	signedKeyList string,
+	minEpochID int,
+	vrfPublicKeyBase64 string,
+	rootHashHex string,
+	proof *InsertionProof,
+	options *ProofOptions,
+) error {
+	publicKey, err := parseVRFPublicKey(vrfPublicKeyBase64)
 	if err != nil {
 		return errors.Wrap(err, "ktclient: VRF proof")
 	}
-	treePath := append(
-		vrfHash[0:28],
-		byte(revision>>24), byte(revision>>16),
-		byte(revision>>8), byte(revision),
-	)
-	hashFunc := sha256.New()
-	emptyNode := make([]byte, hashFunc.Size())
-	leafHash, err := computeLeafNode(proof, emptyNode, hashFunc, minEpochID, signedKeyList)
+
+	return verifyInsertionProof(email, revision, signedKeyList, minEpochID, publicKey, rootHashHex, proof, options)
+}
+
+// VerifyInsertionProofWithTranscript is VerifyInsertionProofWithOptions, also
+// recording the steps of the verification in the transcript, whether it
+// succeeds or not.
+func VerifyInsertionProofWithTranscript(
+	email string,
+	revision int,
+	signedKeyList string,
+	minEpochID int,
+	vrfPublicKeyBase64 string,
+	rootHashHex string,
+	proof *InsertionProof,
+	options *ProofOptions,
+	transcript *Transcript,
+) error {
+	var recorded ProofOptions
+	if options != nil {
+		recorded = *options
+	}
+	recorded.transcript = transcript
+	step := transcript.begin("VRF public key")
+	step.detail("public key", vrfPublicKeyBase64)
+	publicKey, err := parseVRFPublicKey(vrfPublicKeyBase64)
+	if err = step.end(err); err == nil {
+		err = verifyInsertionProof(email, revision, signedKeyList, minEpochID, publicKey, rootHashHex, proof, &recorded)
+	} else {
+		err = errors.Wrap(err, "ktclient: VRF proof")
+	}
+	if transcript != nil {
+		transcript.finish(err)
+	}
+
+	return err
+}
+
+func verifyInsertionProof(
+	email string,
+	revision int,
+	signedKeyList string,
+	minEpochID int,
+	vrfPublicKey *ecvrf.PublicKey,
+	rootHashHex string,
+	proof *InsertionProof,
+	options *ProofOptions,
+) error {
+	if options == nil {
+		options = &ProofOptions{} //nolint:exhaustruct
+	}
+	if !options.SkipEmailCanonicalization {
+		step := options.transcript.begin("email canonicalization")
+		step.detail("email", email)
+		canonicalEmail, err := CanonicalizeEmail(email)
+		step.detail("canonical email", canonicalEmail)
+		if err = step.end(err); err != nil {
+			return err
+		}
+		email = canonicalEmail
+	}
+	step := options.transcript.begin("VRF proof")
+	step.detail("email", email)
+	step.detail("VRF proof", proof.VRFProofHex)
+	vrfHash, err := verifyVRFOutputWithKey(email, proof.VRFProofHex, vrfPublicKey)
+	step.detailf("VRF output", "%x", vrfHash)
+	if err = step.end(err); err != nil {
+		return errors.Wrap(err, "ktclient: VRF proof")
+	}
+	treeVersion, err := resolveTreeVersion(options.TreeVersion)
 	if err != nil {
 		return err
 	}
-	computedRootHash, err := computeRootHash(treePath, proof, emptyNode, leafHash, hashFunc)
+	treePath := treeVersion.treePath(vrfHash, revision)
+	suite, err := resolveHashSuite(options.HashSuite, treeVersion)
 	if err != nil {
 		return err
 	}
+	step = options.transcript.begin("leaf")
+	step.detail("hash suite", suite.Name())
+	step.detail("proof type", proof.ProofType)
+	step.detail("min epoch ID", minEpochID)
+	leafHash, err := computeLeafNode(proof, suite, treeVersion, minEpochID, signedKeyList)
+	step.detailf("leaf hash", "%x", leafHash)
+	if err = step.end(err); err != nil {
+		return err
+	}
+	step = options.transcript.begin("root hash")
+	step.detail("tree version", treeVersion.nameVersion())
+	step.detail("revision", revision)
+	step.detailf("tree path", "%x", treePath)
+	step.detail("neighbours", len(proof.Neighbours))
+	step.detail("expected root hash", rootHashHex)
+	computedRootHash, err := computeRootHash(treePath, proof, leafHash, suite)
+	step.detailf("computed root hash", "%x", computedRootHash)
+	if err == nil {
+		err = compareRootHash(computedRootHash, rootHashHex)
+	}
+
+	return step.end(err)
+}
+
+func compareRootHash(computedRootHash []byte, rootHashHex string) error {
 	rootHash, err := decodeHex(rootHashHex)
 	if err != nil {
 		return errors.Wrap(err, "ktclient: invalid root hash hex encoding")
@@ -64,12 +192,10 @@ func VerifyInsertionProof(
 func computeRootHash(
 	treePath []byte,
 	proof *InsertionProof,
-	emptyNode []byte,
 	leafNode []byte,
-	hashFunc hash.Hash,
+	suite HashSuite,
 ) ([]byte, error) {
 	currentHash := leafNode
-	var concat []byte
 	reachedNonEmptyTree := false
 	for treeLevel := 255; treeLevel >= 0; treeLevel-- {
 		bit := (treePath[treeLevel/8] >> (8 - (treeLevel % 8) - 1)) & 0x01
@@ -78,21 +204,15 @@ func computeRootHash(
 			if !reachedNonEmptyTree && proof.ProofType == absenceProofType {
 				continue
 			}
-			neighbour = emptyNode
+			neighbour = suite.EmptyNode()
 		} else {
 			reachedNonEmptyTree = true
 		}
 		if bit == 0 {
-			concat = append(currentHash, neighbour...)
+			currentHash = suite.NodeHash(currentHash, neighbour)
 		} else {
-			concat = append(neighbour, currentHash...)
-		}
-		hashFunc.Reset()
-		_, err := hashFunc.Write(concat)
-		if err != nil {
-			return nil, errors.Wrap(err, "ktclient: error while hashing")
+			currentHash = suite.NodeHash(neighbour, currentHash)
 		}
-		currentHash = hashFunc.Sum(nil)
 	}
 
 	return currentHash, nil
@@ -100,32 +220,25 @@ func computeRootHash(
 
 func computeLeafNode(
 	proof *InsertionProof,
-	emptyNode []byte,
-	hashFunc hash.Hash,
+	suite HashSuite,
+	treeVersion TreeVersion,
 	minEpochID int,
 	signedKeyList string,
 ) ([]byte, error) {
 	var currentHash []byte
 	switch proof.ProofType {
 	case absenceProofType:
-		currentHash = emptyNode
+		currentHash = suite.EmptyNode()
 	case presenceProofType, obsolescenceProofType:
+		if treeVersion == TreeVersion0 {
+			return suite.LeafHash([]byte(signedKeyList)), nil
+		}
 		minEpochIDBytes := []byte{
 			byte(minEpochID >> 24), byte(minEpochID >> 16),
 			byte(minEpochID >> 8), byte(minEpochID),
 		}
-		hashFunc.Reset()
-		_, err := hashFunc.Write([]byte(signedKeyList))
-		if err != nil {
-			return nil, errors.Wrap(err, "ktclient: error while hashing")
-		}
-		leaf := append(hashFunc.Sum(nil), minEpochIDBytes...)
-		hashFunc.Reset()
-		_, err = hashFunc.Write(leaf)
-		if err != nil {
-			return nil, errors.Wrap(err, "ktclient: error while hashing")
-		}
-		currentHash = hashFunc.Sum(nil)
+		leaf := append(suite.LeafHash([]byte(signedKeyList)), minEpochIDBytes...)
+		currentHash = suite.LeafHash(leaf)
 	default:
 		return nil, errors.Wrapf(errMerkleProof, "ktclient: unknown proof type: %d", proof.ProofType)
 	}
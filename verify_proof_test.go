@@ -2,6 +2,7 @@ package ktclient
 
 import (
 	"encoding/hex"
+	"strings"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -35,16 +36,26 @@ func (testData *TestData) getProof() (*InsertionProof, error) {
 	}, nil
 }
 
-func TestValidExistenceProof(t *testing.T) {
-	t.Parallel()
-	testData := &TestData{
+// The presence proof of the first revision of the signed key list of
+// testSKLEmail, as served by the API.
+const (
+	testSKLEmail      = "kttests@willis.proton.black"
+	testSKLVRFProof   = "4231d686832adf245ffa6321a063cdd2e88f739d2708b195fb4e343db13c816c15110d16a14814fe3f8f7c819aca9c2794d90287d197a00caa943e22ed8665f3004bb8848a9fb1f578b017f34962ec02" //nolint:lll
+	testSKLRootHash   = "84d99a676ae5985ded5aecd61ed2aa8d72655ae328b1dc53d2c53bc2c26c1dd9"
+	testSignedKeyList = `[{"Primary":1,"Flags":3,"Fingerprint":"43eb8f7cc59576c0bca4414258518450b9119b5d","SHA256Fingerprints":["357f701a502e62192022d363af687d52308352ef8ac53a8bd139fa2b9dd3c6a9","d2c59421d8dea08f7d3e0a41a301a245fbe834ef0ec7e96fd6ee870fe75e45ac"]}]` //nolint:lll
+)
+
+// newTestSKLProofData returns the presence proof of testSignedKeyList,
+// for tests to modify.
+func newTestSKLProofData() *TestData {
+	return &TestData{
 		proofType:     presenceProofType,
-		email:         "kttests@willis.proton.black",
-		vrfProof:      "4231d686832adf245ffa6321a063cdd2e88f739d2708b195fb4e343db13c816c15110d16a14814fe3f8f7c819aca9c2794d90287d197a00caa943e22ed8665f3004bb8848a9fb1f578b017f34962ec02", //nolint: lll
-		rootHash:      "84d99a676ae5985ded5aecd61ed2aa8d72655ae328b1dc53d2c53bc2c26c1dd9",
+		email:         testSKLEmail,
+		vrfProof:      testSKLVRFProof,
+		rootHash:      testSKLRootHash,
 		revision:      1,
 		minEpochID:    571,
-		signedKeyList: `[{"Primary":1,"Flags":3,"Fingerprint":"43eb8f7cc59576c0bca4414258518450b9119b5d","SHA256Fingerprints":["357f701a502e62192022d363af687d52308352ef8ac53a8bd139fa2b9dd3c6a9","d2c59421d8dea08f7d3e0a41a301a245fbe834ef0ec7e96fd6ee870fe75e45ac"]}]`, //nolint: lll
+		signedKeyList: testSignedKeyList,
 		neighbours: map[uint8]string{
 			0: "03ed34a89422d83338dca4ed9bbc4a66b1d27e82e57552b5ac8d21c1ed9099d5",
 			1: "1ed3b9e5d0ed19a5f058dfbbd2511bb2d7191126e5c276e99f38a616f5d7d3f1",
@@ -54,6 +65,11 @@ func TestValidExistenceProof(t *testing.T) {
 			7: "d6a370681090122cfe9eb29001feddc1dea2ef0a48a44a2947d14ac2e486cfd8",
 		},
 	}
+}
+
+func TestValidExistenceProof(t *testing.T) {
+	t.Parallel()
+	testData := newTestSKLProofData()
 	proof, err := testData.getProof()
 	if err != nil {
 		t.Fatal(err)
@@ -112,24 +128,10 @@ func TestValidObsolescenceProof(t *testing.T) {
 
 func TestValidAbsenceProof(t *testing.T) {
 	t.Parallel()
-	testData := &TestData{
-		proofType:     absenceProofType,
-		email:         "kttests@willis.proton.black",
-		vrfProof:      "4231d686832adf245ffa6321a063cdd2e88f739d2708b195fb4e343db13c816c15110d16a14814fe3f8f7c819aca9c2794d90287d197a00caa943e22ed8665f3004bb8848a9fb1f578b017f34962ec02", //nolint: lll
-		rootHash:      "84d99a676ae5985ded5aecd61ed2aa8d72655ae328b1dc53d2c53bc2c26c1dd9",
-		revision:      2,
-		minEpochID:    0,
-		signedKeyList: "",
-		neighbours: map[uint8]string{
-			0:   "03ed34a89422d83338dca4ed9bbc4a66b1d27e82e57552b5ac8d21c1ed9099d5",
-			1:   "1ed3b9e5d0ed19a5f058dfbbd2511bb2d7191126e5c276e99f38a616f5d7d3f1",
-			2:   "27cca2c43a813a001c307aa6c8edc7568d9412100ff234c0524b47c78ac19488",
-			3:   "2875a21426aedacc16ece71ed250eeb940c97c1bf990eebc5b265468b11b009c",
-			4:   "87047a94f7bed857880e6c0434e11b56258db98cc792856847a87edb8778270f",
-			7:   "d6a370681090122cfe9eb29001feddc1dea2ef0a48a44a2947d14ac2e486cfd8",
-			254: "10bab3850f37d0448992ae971bcff00ecb63110fc372ae6a4bdf84638a70aa7a",
-		},
-	}
+	testData := newTestSKLProofData()
+	testData.proofType = absenceProofType
+	testData.revision, testData.minEpochID, testData.signedKeyList = 2, 0, ""
+	testData.neighbours[254] = "10bab3850f37d0448992ae971bcff00ecb63110fc372ae6a4bdf84638a70aa7a"
 	proof, err := testData.getProof()
 	if err != nil {
 		t.Fatal(err)
@@ -150,23 +152,8 @@ func TestValidAbsenceProof(t *testing.T) {
 
 func TestBadMerkleProof(t *testing.T) {
 	t.Parallel()
-	testData := &TestData{
-		proofType:     presenceProofType,
-		email:         "kttests@willis.proton.black",
-		vrfProof:      "4231d686832adf245ffa6321a063cdd2e88f739d2708b195fb4e343db13c816c15110d16a14814fe3f8f7c819aca9c2794d90287d197a00caa943e22ed8665f3004bb8848a9fb1f578b017f34962ec02", //nolint: lll
-		rootHash:      "84d99a676ae5985ded5aecd61ed2aa8d72655ae328b1dc53d2c53bc2c26c1dd9",
-		revision:      1,
-		minEpochID:    571,
-		signedKeyList: `[{"Primary":1,"Flags":3,"Fingerprint":"43eb8f7cc59576c0bca4414258518450b9119b5d","SHA256Fingerprints":["357f701a502e62192022d363af687d52308352ef8ac53a8bd139fa2b9dd3c6a9","d2c59421d8dea08f7d3e0a41a301a245fbe834ef0ec7e96fd6ee870fe75e45ac"]}]`, //nolint: lll
-		neighbours: map[uint8]string{
-			0: "03ed34a89422d83338dca4ed9bbc4a66b1d27e82e57552b5ac8d21c1ed9099d5",
-			1: "1ed3b9e5d0ed19a5f058dfbbd2511bb2d7191126e5c276e99f38a616f5d7d3f1",
-			2: "27cca2c43a813a001c307aa6c8edc7568d9412100ff234c0524b47c78ac19488",
-			3: "2875a21426aedacc16ece71ed250eeb940c97c1bf990eebc5b265468b11b009c",
-			4: "87047a94f7bed857880e6c0434e11b56258db98cc792856847a87edb8778270f",
-			7: "d6a370681090122cfe9eb29001feddcdf3a2ef0a48a44a2947d14ac2e486cfd8",
-		},
-	}
+	testData := newTestSKLProofData()
+	testData.neighbours[7] = "d6a370681090122cfe9eb29001feddcdf3a2ef0a48a44a2947d14ac2e486cfd8"
 	proof, err := testData.getProof()
 	if err != nil {
 		t.Fatal(err)
@@ -187,23 +174,8 @@ func TestBadMerkleProof(t *testing.T) {
 
 func TestModifiedSKLError(t *testing.T) {
 	t.Parallel()
-	testData := &TestData{
-		proofType:     presenceProofType,
-		email:         "kttests@willis.proton.black",
-		vrfProof:      "4231d686832adf245ffa6321a063cdd2e88f739d2708b195fb4e343db13c816c15110d16a14814fe3f8f7c819aca9c2794d90287d197a00caa943e22ed8665f3004bb8848a9fb1f578b017f34962ec02", //nolint: lll
-		rootHash:      "84d99a676ae5985ded5aecd61ed2aa8d72655ae328b1dc53d2c53bc2c26c1dd9",
-		revision:      1,
-		minEpochID:    571,
-		signedKeyList: `[{"Primary":1,"Flags":15,"Fingerprint":"43eb8f7cc59576c0bca4414258518450b9119b5d","SHA256Fingerprints":["357f701a502e62192022d363af687d52308352ef8ac53a8bd139fa2b9dd3c6a9","d2c59421d8dea08f7d3e0a41a301a245fbe834ef0ec7e96fd6ee870fe75e45ac"]}]`, //nolint: lll
-		neighbours: map[uint8]string{
-			0: "03ed34a89422d83338dca4ed9bbc4a66b1d27e82e57552b5ac8d21c1ed9099d5",
-			1: "1ed3b9e5d0ed19a5f058dfbbd2511bb2d7191126e5c276e99f38a616f5d7d3f1",
-			2: "27cca2c43a813a001c307aa6c8edc7568d9412100ff234c0524b47c78ac19488",
-			3: "2875a21426aedacc16ece71ed250eeb940c97c1bf990eebc5b265468b11b009c",
-			4: "87047a94f7bed857880e6c0434e11b56258db98cc792856847a87edb8778270f",
-			7: "d6a370681090122cfe9eb29001feddc1dea2ef0a48a44a2947d14ac2e486cfd8",
-		},
-	}
+	testData := newTestSKLProofData()
+	testData.signedKeyList = strings.Replace(testSignedKeyList, `"Flags":3`, `"Flags":15`, 1)
 	proof, err := testData.getProof()
 	if err != nil {
 		t.Fatal(err)
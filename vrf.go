@@ -1,9 +1,11 @@
 package ktclient
 
 import (
+	"crypto/ed25519"
 	"encoding/base64"
 	"fmt"
 
+	"filippo.io/edwards25519"
 	"github.com/ProtonMail/go-ecvrf/ecvrf"
 	"github.com/pkg/errors"
 )
@@ -13,14 +15,38 @@ func verifyVRFOutput(
 	vrfProofHex string,
 	vrfPublicKeyBase64 string,
 ) ([]byte, error) {
+	publicKey, err := parseVRFPublicKey(vrfPublicKeyBase64)
+	if err != nil {
+		return nil, err
+	}
+
+	return verifyVRFOutputWithKey(email, vrfProofHex, publicKey)
+}
+
+func parseVRFPublicKey(vrfPublicKeyBase64 string) (*ecvrf.PublicKey, error) {
 	vrfPublicKey, err := base64.StdEncoding.DecodeString(vrfPublicKeyBase64)
 	if err != nil {
 		return nil, errors.Wrap(err, "ktclient: can't decode VRF key")
 	}
+	if len(vrfPublicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("ktclient: %w: invalid length %d", errVRFKey, len(vrfPublicKey))
+	}
+	if _, err := new(edwards25519.Point).SetBytes(vrfPublicKey); err != nil {
+		return nil, fmt.Errorf("ktclient: %w: not a curve point", errVRFKey)
+	}
 	publicKey, err := ecvrf.NewPublicKey(vrfPublicKey)
 	if err != nil {
 		return nil, errors.Wrap(err, "ktclient: VRF key")
 	}
+
+	return publicKey, nil
+}
+
+func verifyVRFOutputWithKey(
+	email string,
+	vrfProofHex string,
+	publicKey *ecvrf.PublicKey,
+) ([]byte, error) {
 	vrfProof, err := decodeHex(vrfProofHex)
 	if err != nil {
 		return nil, errors.Wrap(err, "ktclient: VRF proof hex decoding")
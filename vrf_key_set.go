@@ -0,0 +1,174 @@
+package ktclient
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ProtonMail/go-ecvrf/ecvrf"
+	"github.com/pkg/errors"
+)
+
+// vrfKeySetStorageKey is the Storage key under which a VRFKeySet is saved.
+const vrfKeySetStorageKey = "ktclient/vrf-key-set"
+
+// VRFKeySet pins the VRF public keys of the server, each bound to the
+// epochs it is valid for. A key is valid from its first epoch ID until the
+// epoch before the first epoch ID of the next key; the last key has no end.
+type VRFKeySet struct {
+	keys []vrfKey
+}
+
+type vrfKey struct {
+	publicKeyBase64 string
+	publicKey       *ecvrf.PublicKey
+	firstEpochID    int
+}
+
+// vrfKeyJSON is the serialized form of a key of a VRFKeySet.
+type vrfKeyJSON struct {
+	PublicKey    string
+	FirstEpochID int
+}
+
+// NewVRFKeySet creates an empty VRFKeySet.
+func NewVRFKeySet() *VRFKeySet {
+	return &VRFKeySet{keys: nil}
+}
+
+// AddKey pins a new VRF public key, valid from firstEpochID onwards.
+// It ends the validity of the previous key, so firstEpochID
+// must be greater than the first epoch ID of every pinned key.
+func (s *VRFKeySet) AddKey(publicKeyBase64 string, firstEpochID int) error {
+	publicKey, err := parseVRFPublicKey(publicKeyBase64)
+	if err != nil {
+		return err
+	}
+	if len(s.keys) > 0 && firstEpochID <= s.keys[len(s.keys)-1].firstEpochID {
+		return fmt.Errorf(
+			"ktclient: %w: key for epoch %d added after key for epoch %d",
+			errVRFKey, firstEpochID, s.keys[len(s.keys)-1].firstEpochID,
+		)
+	}
+	s.keys = append(s.keys, vrfKey{
+		publicKeyBase64: publicKeyBase64,
+		publicKey:       publicKey,
+		firstEpochID:    firstEpochID,
+	})
+
+	return nil
+}
+
+// KeyForEpoch returns the base64 encoded VRF public key valid for the epoch.
+func (s *VRFKeySet) KeyForEpoch(epochID int) (string, error) {
+	key, err := s.keyForEpoch(epochID)
+	if err != nil {
+		return "", err
+	}
+
+	return key.publicKeyBase64, nil
+}
+
+// CheckKey returns an error unless publicKeyBase64 is the key pinned for
+// the epoch, e.g. to validate a key supplied along with a proof.
+func (s *VRFKeySet) CheckKey(publicKeyBase64 string, epochID int) error {
+	key, err := s.keyForEpoch(epochID)
+	if err != nil {
+		return err
+	}
+	if key.publicKeyBase64 != publicKeyBase64 {
+		return fmt.Errorf("ktclient: %w: key is not the one pinned for epoch %d", errVRFKey, epochID)
+	}
+
+	return nil
+}
+
+func (s *VRFKeySet) keyForEpoch(epochID int) (*vrfKey, error) {
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		if s.keys[i].firstEpochID <= epochID {
+			return &s.keys[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("ktclient: %w: no key pinned for epoch %d", errVRFKey, epochID)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s *VRFKeySet) MarshalJSON() ([]byte, error) {
+	keys := make([]vrfKeyJSON, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, vrfKeyJSON{
+			PublicKey:    key.publicKeyBase64,
+			FirstEpochID: key.firstEpochID,
+		})
+	}
+
+	return json.Marshal(keys) //nolint:wrapcheck
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// The keys are validated as if added with AddKey.
+func (s *VRFKeySet) UnmarshalJSON(data []byte) error {
+	var keys []vrfKeyJSON
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return errors.Wrap(err, "ktclient: invalid VRF key set encoding")
+	}
+	keySet := NewVRFKeySet()
+	for _, key := range keys {
+		if err := keySet.AddKey(key.PublicKey, key.FirstEpochID); err != nil {
+			return err
+		}
+	}
+	*s = *keySet
+
+	return nil
+}
+
+// Save persists the key set to the storage.
+func (s *VRFKeySet) Save(storage Storage) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "ktclient: encode VRF key set")
+	}
+
+	return errors.Wrap(storage.Set(vrfKeySetStorageKey, data), "ktclient: save VRF key set")
+}
+
+// LoadVRFKeySet loads the key set saved to the storage,
+// or returns an empty key set if none was saved.
+func LoadVRFKeySet(storage Storage) (*VRFKeySet, error) {
+	data, err := storage.Get(vrfKeySetStorageKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "ktclient: load VRF key set")
+	}
+	keySet := NewVRFKeySet()
+	if data == nil {
+		return keySet, nil
+	}
+	if err := json.Unmarshal(data, keySet); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return keySet, nil
+}
+
+// VerifyInsertionProofWithKeySet is VerifyInsertionProofWithOptions using the
+// VRF key pinned for the epoch whose root hash the proof leads to.
+// It fails if no key of the set is valid for that epoch.
+func VerifyInsertionProofWithKeySet(
+	email string,
+	revision int,
+	signedKeyList string,
+	minEpochID int,
+	keySet *VRFKeySet,
+	epochID int,
+	rootHashHex string,
+	proof *InsertionProof,
+	options *ProofOptions,
+) error {
+	key, err := keySet.keyForEpoch(epochID)
+	if err != nil {
+		return err
+	}
+
+	return verifyInsertionProof(email, revision, signedKeyList, minEpochID, key.publicKey, rootHashHex, proof, options)
+}
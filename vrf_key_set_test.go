@@ -0,0 +1,98 @@
+package ktclient
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRotatedVRFPublicKey = "RxDNupPzI60w9Dsb/nuVuQ4Nh6KeQ5gTgmwEnO8xqN8="
+
+func TestVRFKeySetEpochRanges(t *testing.T) {
+	t.Parallel()
+	keySet := NewVRFKeySet()
+	assert.NoError(t, keySet.AddKey(testVRFPublicKey, 100))
+	assert.NoError(t, keySet.AddKey(testRotatedVRFPublicKey, 600))
+
+	_, err := keySet.KeyForEpoch(99)
+	assert.True(t, errors.Is(err, errVRFKey))
+	for epochID, expected := range map[int]string{
+		100:  testVRFPublicKey,
+		599:  testVRFPublicKey,
+		600:  testRotatedVRFPublicKey,
+		5000: testRotatedVRFPublicKey,
+	} {
+		key, err := keySet.KeyForEpoch(epochID)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, key)
+	}
+	assert.NoError(t, keySet.CheckKey(testVRFPublicKey, 571))
+	assert.True(t, errors.Is(keySet.CheckKey(testRotatedVRFPublicKey, 571), errVRFKey))
+}
+
+func TestVRFKeySetRejectsInvalidKeys(t *testing.T) {
+	t.Parallel()
+	keySet := NewVRFKeySet()
+	assert.Error(t, keySet.AddKey("not base64!", 1))
+	assert.Error(t, keySet.AddKey("AAAA", 1))
+	assert.NoError(t, keySet.AddKey(testVRFPublicKey, 10))
+	assert.True(t, errors.Is(keySet.AddKey(testRotatedVRFPublicKey, 10), errVRFKey))
+	assert.True(t, errors.Is(keySet.AddKey(testRotatedVRFPublicKey, 5), errVRFKey))
+}
+
+func TestVRFKeySetPersistence(t *testing.T) {
+	t.Parallel()
+	storage := NewMemoryStorage()
+	empty, err := LoadVRFKeySet(storage)
+	assert.NoError(t, err)
+	_, err = empty.KeyForEpoch(1)
+	assert.Error(t, err)
+
+	keySet := NewVRFKeySet()
+	assert.NoError(t, keySet.AddKey(testVRFPublicKey, 1))
+	assert.NoError(t, keySet.AddKey(testRotatedVRFPublicKey, 600))
+	assert.NoError(t, keySet.Save(storage))
+
+	loaded, err := LoadVRFKeySet(storage)
+	assert.NoError(t, err)
+	assert.Equal(t, keySet, loaded)
+
+	tampered := NewMemoryStorage()
+	assert.NoError(t, tampered.Set(vrfKeySetStorageKey, []byte(`[{"PublicKey":"AAAA","FirstEpochID":1}]`)))
+	_, err = LoadVRFKeySet(tampered)
+	assert.Error(t, err)
+
+	var decoded VRFKeySet
+	assert.Error(t, json.Unmarshal([]byte(`{}`), &decoded))
+}
+
+func TestInsertionProofWithKeySet(t *testing.T) {
+	t.Parallel()
+	testData := newTestSKLProofData()
+	proof, err := testData.getProof()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySet := NewVRFKeySet()
+	assert.NoError(t, keySet.AddKey(testVRFPublicKey, 500))
+	assert.NoError(t, keySet.AddKey(testRotatedVRFPublicKey, 600))
+
+	verify := func(epochID int) error {
+		return VerifyInsertionProofWithKeySet(
+			testData.email,
+			testData.revision,
+			testData.signedKeyList,
+			testData.minEpochID,
+			keySet,
+			epochID,
+			testData.rootHash,
+			proof,
+			nil,
+		)
+	}
+	assert.NoError(t, verify(571))
+	assert.True(t, errors.Is(verify(400), errVRFKey))
+	assert.True(t, errors.Is(verify(600), errVRFProof))
+}
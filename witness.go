@@ -0,0 +1,73 @@
+package ktclient
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+// WitnessPolicy requires epochs to be cosigned by a threshold of independent
+// witnesses. Witnesses cosign the checkpoint of the epoch (epoch ID, chain
+// hash and certificate time) with signed note signatures, see
+// https://github.com/transparency-dev/formats.
+type WitnessPolicy struct {
+	threshold int
+	verifiers []note.Verifier
+}
+
+// NewWitnessPolicy creates a policy requiring threshold cosignatures
+// from the witnesses added with AddWitness.
+func NewWitnessPolicy(threshold int) *WitnessPolicy {
+	return &WitnessPolicy{threshold: threshold, verifiers: nil}
+}
+
+// AddWitness adds a witness, given its signed note verifier key
+// in the <name>+<hash>+<base64 key> format.
+func (p *WitnessPolicy) AddWitness(verifierKey string) error {
+	verifier, err := note.NewVerifier(verifierKey)
+	if err != nil {
+		return fmt.Errorf("ktclient: %w: invalid verifier key: %v", errWitness, err) //nolint:errorlint
+	}
+	for _, known := range p.verifiers {
+		if known.Name() == verifier.Name() {
+			return fmt.Errorf("ktclient: %w: witness %s added twice", errWitness, verifier.Name())
+		}
+	}
+	p.verifiers = append(p.verifiers, verifier)
+
+	return nil
+}
+
+// verifyCosignatures checks that the signature lines of a signed note
+// contain valid cosignatures of the checkpoint body from enough witnesses.
+func (p *WitnessPolicy) verifyCosignatures(checkpointBody string, cosignatures string) error {
+	if p.threshold <= 0 || p.threshold > len(p.verifiers) {
+		return fmt.Errorf(
+			"ktclient: %w: threshold %d with %d witnesses", errWitness, p.threshold, len(p.verifiers),
+		)
+	}
+	if !strings.HasSuffix(cosignatures, "\n") {
+		cosignatures += "\n"
+	}
+	signedNote, err := note.Open([]byte(checkpointBody+"\n"+cosignatures), note.VerifierList(p.verifiers...))
+	var unverified *note.UnverifiedNoteError
+	switch {
+	case errors.As(err, &unverified):
+		return fmt.Errorf("ktclient: %w: no cosignature from a known witness", errWitness)
+	case err != nil:
+		return fmt.Errorf("ktclient: %w: %v", errWitness, err) //nolint:errorlint
+	}
+	witnesses := make(map[string]bool)
+	for _, signature := range signedNote.Sigs {
+		witnesses[signature.Name] = true
+	}
+	if len(witnesses) < p.threshold {
+		return fmt.Errorf(
+			"ktclient: %w: %d of %d required cosignatures", errWitness, len(witnesses), p.threshold,
+		)
+	}
+
+	return nil
+}
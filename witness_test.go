@@ -0,0 +1,126 @@
+package ktclient
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mod/sumdb/note"
+)
+
+type testWitness struct {
+	signer      note.Signer
+	verifierKey string
+}
+
+func newTestWitness(t *testing.T, name string) *testWitness {
+	t.Helper()
+	signerKey, verifierKey, err := note.GenerateKey(rand.Reader, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := note.NewSigner(signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testWitness{signer: signer, verifierKey: verifierKey}
+}
+
+// cosignTestEpoch returns the signature lines of the checkpoint of the epoch
+// signed by the witnesses.
+func cosignTestEpoch(t *testing.T, epoch *Epoch, baseDomain string, witnesses ...*testWitness) string {
+	t.Helper()
+	chainHash, err := hex.DecodeString(epoch.ChainHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := epochCheckpointBody(epoch.EpochID, chainHash, epoch.CertificateTime, baseDomain)
+	signers := make([]note.Signer, 0, len(witnesses))
+	for _, witness := range witnesses {
+		signers = append(signers, witness.signer)
+	}
+	signed, err := note.Sign(&note.Note{Text: body}, signers...) //nolint:exhaustruct
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.TrimPrefix(string(signed), body+"\n")
+}
+
+func newTestEpoch() *Epoch {
+	return &Epoch{ //nolint:exhaustruct
+		EpochID:           46,
+		PreviousChainHash: "9624e880fe4b49b45fc15ca7e16b7ed8a846724a1802b2a2ca9d749770b00f4b",
+		CertificateChain:  certificateChain,
+		CertificateIssuer: 1,
+		TreeHash:          "65e8dd7b133f7a02fe0e249928f7fdf1a21df8a5923234c97e0177f0f56a194c",
+		ChainHash:         "506062a81b4f2ae8aeb2f6dd2d003adac3cd1e84a39d19225a8653d1012cf0d2",
+		CertificateTime:   1_689_062_740,
+	}
+}
+
+func TestEpochWitnessQuorum(t *testing.T) {
+	t.Parallel()
+	alice, bob, carol := newTestWitness(t, "alice"), newTestWitness(t, "bob"), newTestWitness(t, "carol")
+	mallory := newTestWitness(t, "mallory")
+	policy := NewWitnessPolicy(2)
+	for _, witness := range []*testWitness{alice, bob, carol} {
+		assert.NoError(t, policy.AddWitness(witness.verifierKey))
+	}
+	options := &EpochOptions{WitnessPolicy: policy} //nolint:exhaustruct
+
+	for name, testCase := range map[string]struct {
+		witnesses []*testWitness
+		valid     bool
+	}{
+		"quorum":            {[]*testWitness{alice, carol}, true},
+		"all witnesses":     {[]*testWitness{alice, bob, carol}, true},
+		"unknown witnesses": {[]*testWitness{alice, mallory, carol}, true},
+		"single witness":    {[]*testWitness{bob}, false},
+		"unknown witness":   {[]*testWitness{bob, mallory}, false},
+		"only unknown":      {[]*testWitness{mallory}, false},
+	} {
+		epoch := newTestEpoch()
+		epoch.Cosignatures = cosignTestEpoch(t, epoch, "dev.proton.wtf", testCase.witnesses...)
+		_, err := VerifyEpochWithOptions(epoch, "dev.proton.wtf", 1_689_062_740, options)
+		if testCase.valid {
+			assert.NoError(t, err, name)
+		} else {
+			assert.True(t, errors.Is(err, errWitness), name)
+		}
+	}
+
+	epoch := newTestEpoch()
+	_, err := VerifyEpochWithOptions(epoch, "dev.proton.wtf", 1_689_062_740, options)
+	assert.True(t, errors.Is(err, errWitness), "no cosignatures")
+	epoch.Cosignatures = cosignTestEpoch(t, epoch, "proton.me", alice, bob)
+	_, err = VerifyEpochWithOptions(epoch, "dev.proton.wtf", 1_689_062_740, options)
+	assert.True(t, errors.Is(err, errWitness), "cosigned for another domain")
+	epoch.Cosignatures = cosignTestEpoch(t, epoch, "dev.proton.wtf", alice, bob)
+	epoch.CertificateTime++
+	_, err = VerifyEpochWithOptions(epoch, "dev.proton.wtf", 1_689_062_740, options)
+	assert.Error(t, err, "cosigned for another time")
+}
+
+func TestWitnessPolicyConfiguration(t *testing.T) {
+	t.Parallel()
+	alice := newTestWitness(t, "alice")
+	policy := NewWitnessPolicy(2)
+	assert.True(t, errors.Is(policy.AddWitness("alice+invalid"), errWitness))
+	assert.NoError(t, policy.AddWitness(alice.verifierKey))
+	assert.True(t, errors.Is(policy.AddWitness(alice.verifierKey), errWitness))
+
+	epoch := newTestEpoch()
+	epoch.Cosignatures = cosignTestEpoch(t, epoch, "dev.proton.wtf", alice)
+	chainHash, _ := hex.DecodeString(epoch.ChainHash)
+	body := epochCheckpointBody(epoch.EpochID, chainHash, epoch.CertificateTime, "dev.proton.wtf")
+	assert.True(t, errors.Is(policy.verifyCosignatures(body, epoch.Cosignatures), errWitness), "threshold too high")
+	assert.True(t, errors.Is(NewWitnessPolicy(0).verifyCosignatures(body, epoch.Cosignatures), errWitness))
+	single := NewWitnessPolicy(1)
+	assert.NoError(t, single.AddWitness(alice.verifierKey))
+	assert.NoError(t, single.verifyCosignatures(body, strings.TrimSuffix(epoch.Cosignatures, "\n")))
+}